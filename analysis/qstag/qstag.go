@@ -0,0 +1,406 @@
+// Package qstag defines an Analyzer that checks the qs struct tags of the
+// github.com/pasztorpisti/qs package at build time.
+//
+// The qs package parses the struct tags only when a type is marshaled or
+// unmarshaled for the first time so a typo in a tag option (e.g.: omitemtpy)
+// surfaces only at runtime. The analyzer reports:
+//
+//   - unknown tag options and invalid option values,
+//   - conflicting options (e.g.: opt and req, default and req),
+//   - duplicate query keys among the fields of a struct including the fields
+//     of embedded structs and the field names that have the same snake_case,
+//   - field types that can't be handled by the default marshaler and
+//     unmarshaler factories of the qs package including the recursive nested
+//     types.
+//
+// Only the structs that have at least one field with a qs tag are checked.
+// The analyzer can be used with go vet through the qsvet command:
+//
+//	go vet -vettool=$(which qsvet) ./...
+package qstag
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strings"
+
+	"github.com/pasztorpisti/qs/internal/tagparse"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const tagKey = "qs"
+
+const qsPkgPath = "github.com/pasztorpisti/qs"
+
+// Analyzer checks the qs struct tags.
+var Analyzer = &analysis.Analyzer{
+	Name:     "qstag",
+	Doc:      "check the qs struct tags of the github.com/pasztorpisti/qs package",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	insp.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		st, ok := pass.TypesInfo.TypeOf(n.(*ast.StructType)).(*types.Struct)
+		if !ok || !hasTag(st) {
+			return
+		}
+		checkStruct(pass, st)
+	})
+	return nil, nil
+}
+
+func hasTag(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if _, ok := reflect.StructTag(st.Tag(i)).Lookup(tagKey); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func checkStruct(pass *analysis.Pass, st *types.Struct) {
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		tagStr := reflect.StructTag(st.Tag(i))
+		if !v.Exported() && !v.Embedded() {
+			if _, ok := tagStr.Lookup(tagKey); ok {
+				pass.Reportf(v.Pos(), "qs tag on unexported field %v is ignored", v.Name())
+			}
+			continue
+		}
+
+		tag, err := parseFieldTag(tagStr)
+		if err != nil {
+			pass.Reportf(v.Pos(), "invalid qs tag on field %v: %v", v.Name(), err)
+			continue
+		}
+		if tag.Name == "-" {
+			continue
+		}
+		if err := checkFieldType(v.Type(), tag); err != nil {
+			pass.Reportf(v.Pos(), "field %v: %v", v.Name(), err)
+		}
+	}
+	checkDuplicateKeys(pass, st)
+}
+
+// parseFieldTag parses a qs tag with the parser of the qs package.
+func parseFieldTag(tagStr reflect.StructTag) (tagparse.Tag, error) {
+	tag, err := tagparse.Parse(tagStr.Get(tagKey))
+	if err != nil {
+		return tag, err
+	}
+	if tag.Remain && tag.UnmarshalPresence == "req" {
+		return tag, errors.New("the remain option can't be combined with the req option")
+	}
+	return tag, nil
+}
+
+// checkFieldType returns an error if the type of a struct field can't be
+// used with the options in its tag or if the default factories of the qs
+// package can't handle it.
+func checkFieldType(t types.Type, tag tagparse.Tag) error {
+	if tag.Remain {
+		if !isStringSliceMap(t) {
+			return fmt.Errorf("the remain option requires a url.Values or map[string][]string field, got %v", t)
+		}
+		return nil
+	}
+
+	if tag.TimeLayout != "" && !isNamed(indirect(unwrapOptional(t)), "time", "Time") {
+		return fmt.Errorf("time layout options can be used only with time.Time fields: %v", t)
+	}
+	vt := t
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		vt = p.Elem()
+	}
+	switch u := vt.Underlying(); tag.Style {
+	case "spaceDelimited", "pipeDelimited":
+		switch u.(type) {
+		case *types.Array, *types.Slice:
+		default:
+			return fmt.Errorf("style %v can be used only with arrays and slices: %v", tag.Style, t)
+		}
+	case "deepObject":
+		switch u.(type) {
+		case *types.Struct, *types.Map:
+		default:
+			return fmt.Errorf("style %v can be used only with structs and maps: %v", tag.Style, t)
+		}
+	}
+	return checkType(t)
+}
+
+// checkType returns an error if the default factories of the qs package can't
+// marshal or unmarshal the values of type t.
+func checkType(t types.Type) error {
+	if hasCustomMarshaling(t) || isNamed(t, "time", "Time") || isNamed(t, "time", "Duration") ||
+		isNamed(t, "net/url", "URL") {
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Kind() == types.Uintptr || u.Kind() == types.UnsafePointer:
+		case u.Info()&(types.IsString|types.IsBoolean|types.IsInteger|types.IsFloat) != 0:
+			return nil
+		}
+	case *types.Pointer:
+		return checkType(u.Elem())
+	case *types.Slice:
+		return checkType(u.Elem())
+	case *types.Array:
+		return checkType(u.Elem())
+	case *types.Map:
+		if !isValidMapKey(u.Key()) {
+			return fmt.Errorf("unsupported map key type: %v", u.Key())
+		}
+		return checkType(u.Elem())
+	case *types.Struct:
+		// The qs package rejects the nested structs that contain
+		// themselves through any chain of types.
+		if isRecursiveType(t) {
+			return fmt.Errorf("recursive nested type: %v", t)
+		}
+		for i := 0; i < u.NumFields(); i++ {
+			v := u.Field(i)
+			if !v.Exported() && !v.Embedded() {
+				continue
+			}
+			tag, err := parseFieldTag(reflect.StructTag(u.Tag(i)))
+			if err != nil || tag.Name == "-" || tag.Remain {
+				// The invalid tags are reported when the nested
+				// struct is checked.
+				continue
+			}
+			if err := checkType(v.Type()); err != nil {
+				return fmt.Errorf("%v: %w", v.Name(), err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported type: %v", t)
+}
+
+// isRecursiveType returns true if the values of type t can contain values of
+// a type that contains itself. It walks the same types as the isRecursiveType
+// function of the qs package: pointers, arrays, slices, maps and the exported
+// and embedded struct fields that aren't skipped with a "-" tag.
+func isRecursiveType(t types.Type) bool {
+	return hasTypeCycle(t, map[types.Type]bool{}, map[types.Type]bool{})
+}
+
+// hasTypeCycle walks the types reachable from t depth-first. path holds the
+// types on the path from the root to t and done the types already known to
+// be free of cycles.
+func hasTypeCycle(t types.Type, path, done map[types.Type]bool) bool {
+	if path[t] {
+		return true
+	}
+	if done[t] {
+		return false
+	}
+	path[t] = true
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		if hasTypeCycle(u.Elem(), path, done) {
+			return true
+		}
+	case *types.Slice:
+		if hasTypeCycle(u.Elem(), path, done) {
+			return true
+		}
+	case *types.Array:
+		if hasTypeCycle(u.Elem(), path, done) {
+			return true
+		}
+	case *types.Map:
+		if hasTypeCycle(u.Elem(), path, done) {
+			return true
+		}
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			v := u.Field(i)
+			if !v.Exported() && !v.Embedded() {
+				continue
+			}
+			if strings.SplitN(reflect.StructTag(u.Tag(i)).Get(tagKey), ",", 2)[0] == "-" {
+				continue
+			}
+			if hasTypeCycle(v.Type(), path, done) {
+				return true
+			}
+		}
+	}
+	delete(path, t)
+	done[t] = true
+	return false
+}
+
+// hasCustomMarshaling returns true if t implements any of the interfaces that
+// are used by the qs package to marshal or unmarshal the values of t.
+func hasCustomMarshaling(t types.Type) bool {
+	if _, ok := t.(*types.Pointer); ok {
+		return false
+	}
+	ms := types.NewMethodSet(types.NewPointer(t))
+	for i := 0; i < ms.Len(); i++ {
+		switch ms.At(i).Obj().Name() {
+		case "MarshalQS", "UnmarshalQS", "MarshalText", "UnmarshalText":
+			return true
+		}
+	}
+	return false
+}
+
+func isValidMapKey(t types.Type) bool {
+	if hasCustomMarshaling(t) {
+		return true
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() != types.Uintptr && b.Info()&(types.IsString|types.IsInteger) != 0
+}
+
+func isStringSliceMap(t types.Type) bool {
+	m, ok := t.Underlying().(*types.Map)
+	if !ok || !types.Identical(m.Key(), types.Typ[types.String]) {
+		return false
+	}
+	s, ok := m.Elem().(*types.Slice)
+	return ok && types.Identical(s.Elem(), types.Typ[types.String])
+}
+
+// indirect returns the element type of pointers, arrays and slices (and
+// pointers in arrays and slices).
+func indirect(t types.Type) types.Type {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		t = u.Elem()
+	case *types.Array:
+		t = u.Elem()
+	default:
+		return t
+	}
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	return t
+}
+
+// unwrapOptional returns T in case of the qs.Optional[T] type and t otherwise.
+func unwrapOptional(t types.Type) types.Type {
+	if n, ok := t.(*types.Named); ok && isNamed(n.Origin(), qsPkgPath, "Optional") && n.TypeArgs().Len() == 1 {
+		return n.TypeArgs().At(0)
+	}
+	return t
+}
+
+func isNamed(t types.Type, pkgPath, name string) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Name() == name && obj.Pkg() != nil && obj.Pkg().Path() == pkgPath
+}
+
+// keyField is a field that marshals into a key of the struct being checked.
+type keyField struct {
+	// Path is the Go path of the field. E.g.: "Embedded.Name".
+	Path string
+	// Depth is the embedding depth of the field.
+	Depth int
+	// Top is the field of the checked struct that holds the field.
+	Top *types.Var
+}
+
+// checkDuplicateKeys reports the keys that are used by more than one field at
+// the same embedding depth. Just like with Go's embedding rules the fields of
+// outer structs shadow the fields of embedded structs.
+func checkDuplicateKeys(pass *analysis.Pass, st *types.Struct) {
+	keys := map[string][]keyField{}
+	var order []string
+	var collect func(st *types.Struct, depth int, prefix string, top *types.Var, seen map[*types.Struct]bool)
+	collect = func(st *types.Struct, depth int, prefix string, top *types.Var, seen map[*types.Struct]bool) {
+		if seen[st] {
+			return
+		}
+		seen[st] = true
+		defer delete(seen, st)
+
+		for i := 0; i < st.NumFields(); i++ {
+			v := st.Field(i)
+			if !v.Exported() && !v.Embedded() {
+				continue
+			}
+			tag, err := parseFieldTag(reflect.StructTag(st.Tag(i)))
+			if err != nil || tag.Name == "-" || tag.Remain {
+				continue
+			}
+			t := top
+			if t == nil {
+				t = v
+			}
+			if v.Embedded() {
+				et := v.Type()
+				if p, ok := et.Underlying().(*types.Pointer); ok {
+					et = p.Elem()
+				}
+				switch u := et.Underlying().(type) {
+				case *types.Struct:
+					collect(u, depth+1, prefix+v.Name()+".", t, seen)
+					continue
+				case *types.Map:
+					// The keys of embedded maps are known only at
+					// runtime.
+					continue
+				}
+				if !v.Exported() {
+					continue
+				}
+			}
+			key := tag.Name
+			if key == "" {
+				key = tagparse.SnakeCase(v.Name())
+			}
+			if _, ok := keys[key]; !ok {
+				order = append(order, key)
+			}
+			keys[key] = append(keys[key], keyField{Path: prefix + v.Name(), Depth: depth, Top: t})
+		}
+	}
+	collect(st, 0, "", nil, map[*types.Struct]bool{})
+
+	for _, key := range order {
+		fields := keys[key]
+		minDepth := fields[0].Depth
+		for _, f := range fields[1:] {
+			if f.Depth < minDepth {
+				minDepth = f.Depth
+			}
+		}
+		var first *keyField
+		for i := range fields {
+			f := &fields[i]
+			if f.Depth != minDepth {
+				continue
+			}
+			if first == nil {
+				first = f
+				continue
+			}
+			pass.Reportf(f.Top.Pos(), "duplicate qs key %q: fields %v and %v", key, first.Path, f.Path)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package qstag_test
+
+import (
+	"testing"
+
+	"github.com/pasztorpisti/qs/analysis/qstag"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), qstag.Analyzer, "a")
+}
@@ -0,0 +1,114 @@
+package a
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/pasztorpisti/qs"
+)
+
+type Valid struct {
+	Name     string                 `qs:"name,omitempty,req"`
+	Page     *int                   `qs:",opt,default=1,min=1"`
+	Sort     string                 `qs:",oneof=name|date"`
+	IDs      []int                  `qs:"ids,style=pipeDelimited,explode=false,maxlen=10"`
+	Since    time.Time              `qs:",unix"`
+	Timeout  time.Duration          `qs:""`
+	Link     url.URL                `qs:""`
+	Filter   map[string]string      `qs:",style=deepObject"`
+	Rest     url.Values             `qs:",remain"`
+	Token    string                 `qs:"X-Token,in=header|query"`
+	Until    qs.Optional[time.Time] `qs:",layout=2006-01-02"`
+	Skipped  chan int               `qs:"-"`
+	internal chan int
+	Embedded
+}
+
+type Embedded struct {
+	Page int // shadowed by Valid.Page
+	Size int
+}
+
+type Typos struct {
+	A string   `qs:",omitemtpy"`           // want `invalid qs tag on field A: invalid option in field tag: "omitemtpy"`
+	B string   `qs:"b,"`                   // want `invalid qs tag on field B: tag string contains a surplus comma`
+	C string   `qs:",opt,req"`             // want `invalid qs tag on field C: only one UnmarshalPresence option is allowed`
+	D string   `qs:",keepempty,omitempty"` // want `invalid qs tag on field D: only one MarshalPresence option is allowed`
+	E string   `qs:",default=x,req"`       // want `invalid qs tag on field E: the default option can't be combined with the req option`
+	F int      `qs:",min=x"`               // want `invalid qs tag on field F: invalid min option`
+	G string   `qs:",pattern=["`           // want `invalid qs tag on field G: invalid pattern option`
+	H string   `qs:",style=matrix"`        // want `invalid qs tag on field H: invalid style: "matrix"`
+	I string   `qs:",minimum=1"`           // want `invalid qs tag on field I: invalid option in field tag: "minimum=1"`
+	j string   `qs:"j"`                    // want `qs tag on unexported field j is ignored`
+	K string   `qs:",unix"`                // want `field K: time layout options can be used only with time.Time fields`
+	L string   `qs:",style=deepObject"`    // want `field L: style deepObject can be used only with structs and maps`
+	M []string `qs:",remain"`              // want `field M: the remain option requires a url.Values or map\[string\]\[\]string field`
+	N string   `qs:",in=body"`             // want `invalid qs tag on field N: invalid in value: "body"`
+}
+
+type Types struct {
+	A chan int          `qs:"a"` // want `field A: unsupported type: chan int`
+	B func()            // want `field B: unsupported type: func\(\)`
+	C complex128        // want `field C: unsupported type: complex128`
+	D map[float64]int   // want `field D: unsupported map key type: float64`
+	E []interface{}     // want `field E: unsupported type: interface{}`
+	F Nested            // want `field F: Ch: unsupported type: chan bool`
+	G map[Key]*TextType // custom marshaling
+	H uintptr           // want `field H: unsupported type: uintptr`
+}
+
+type Recursive struct {
+	A Node  `qs:"a"` // want `field A: recursive nested type: a.Node`
+	B Chain // want `field B: recursive nested type: a.Chain`
+	C *Link // custom marshaling stops the recursion
+}
+
+type Node struct {
+	Name     string
+	Children []Node
+}
+
+type Chain struct {
+	Links map[string]*ChainLink
+}
+
+type ChainLink struct {
+	Back []Chain
+}
+
+type Link struct {
+	Next *Link
+}
+
+func (l Link) MarshalText() ([]byte, error)  { return nil, nil }
+func (l *Link) UnmarshalText(b []byte) error { return nil }
+
+type Nested struct {
+	Name string
+	Ch   chan bool
+}
+
+type Key int
+
+type TextType struct{ x chan int }
+
+func (t TextType) MarshalText() ([]byte, error)  { return nil, nil }
+func (t *TextType) UnmarshalText(b []byte) error { return nil }
+
+type Duplicates struct {
+	UserID string `qs:"user_id"`
+	UserId string // want `duplicate qs key "user_id": fields UserID and UserId`
+	Name   string
+	Label  string `qs:"name"` // want `duplicate qs key "name": fields Name and Label`
+	Inner1
+	Inner2 // want `duplicate qs key "x": fields Inner1.X and Inner2.X`
+}
+
+type Inner1 struct {
+	X    int
+	Name string // shadowed by Duplicates.Name
+}
+
+type Inner2 struct {
+	X int
+}
@@ -0,0 +1,8 @@
+// Package qs is a stub of the qs package for the tests of the analyzer.
+package qs
+
+type Optional[T any] struct {
+	Value   T
+	Present bool
+	Empty   bool
+}
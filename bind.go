@@ -0,0 +1,286 @@
+package qs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// requestSource is a bit set of the parts of an HTTP request that
+// BindRequest reads the value of a field from. It is set by the in option
+// of the field tag (e.g.: `qs:"id,in=path"` or `qs:"id,in=query|path"`).
+type requestSource int
+
+const (
+	sourceQuery requestSource = 1 << iota
+	sourceForm
+	sourcePath
+	sourceHeader
+
+	// defaultSources are used with the fields that don't have an in option
+	// and with the keys that don't belong to any field.
+	defaultSources = sourceQuery | sourceForm | sourcePath
+)
+
+// requestSources converts the items of the in tag option into a
+// requestSource. It returns zero if names is nil.
+func requestSources(names []string) requestSource {
+	var sources requestSource
+	for _, name := range names {
+		switch name {
+		case "query":
+			sources |= sourceQuery
+		case "form":
+			sources |= sourceForm
+		case "path":
+			sources |= sourcePath
+		case "header":
+			sources |= sourceHeader
+		}
+	}
+	return sources
+}
+
+// BindRequest unmarshals the query string, the urlencoded body, the path
+// values and the headers of an HTTP request into a struct. into has to be a
+// pointer to a struct: pointers to maps and other types are rejected because
+// only struct fields can select their sources.
+//
+// The in option of the qs field tag selects the sources of a field as a pipe
+// separated list of query, form, path and header (e.g.: `qs:"id,in=path"`).
+// Fields without an in option are read from the query string, the body and
+// the path values but not from the headers. When a field is present in more
+// than one of its sources the value of the later source in the
+// query < form < path < header order wins. Keys that don't belong to any
+// field (e.g.: the keys of remain fields) are read from the query string and
+// the body.
+//
+// The body is parsed with http.Request.ParseForm so only urlencoded bodies
+// are read. The path values are read with http.Request.PathValue. The
+// header name of a field is the name in its header tag (e.g.:
+// `qs:"request_id,in=header" header:"X-Request-Id"`) or its key with
+// underscores replaced with dashes if it has no header tag (e.g.: request_id
+// is read from the Request-Id header). The header values of slice and array
+// fields are split into items as in case of UnmarshalHeader.
+//
+// The in option can be used only on the fields of the struct and its embedded
+// structs: the fields of nested structs are read from the sources of the field
+// that holds the nested struct so an in option inside them is an error.
+//
+// The merged values are unmarshaled with UnmarshalValues so the unmarshal
+// presence options (opt, nil, req), default values and constraints of the
+// fields work the same way as in case of query strings.
+func BindRequest(r *http.Request, into interface{}) error {
+	return DefaultUnmarshaler.BindRequest(r, into)
+}
+
+// BindRequest unmarshals the parts of an HTTP request into a struct.
+// See the documentation of the global BindRequest func.
+func (p *QSUnmarshaler) BindRequest(r *http.Request, into interface{}) error {
+	t := reflect.TypeOf(into)
+	if t == nil {
+		return errors.New("received an empty interface")
+	}
+	if t.Kind() != reflect.Ptr {
+		return fmt.Errorf("expected a pointer, got %T", into)
+	}
+	fields, err := p.bindFields(t.Elem())
+	if err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("error parsing request form :: %w", err)
+	}
+	return p.UnmarshalValues(into, mergeRequestValues(r, fields, p.opts.KeySyntax))
+}
+
+// bindField describes the sources of the key of a struct field.
+type bindField struct {
+	Sources requestSource
+	// Header is the canonical name of the header of the field.
+	Header string
+	// List is true if the field is unmarshaled from multiple values
+	// (e.g.: slices). The header values of such fields are split into
+	// items.
+	List bool
+}
+
+// bindFields returns the cached bindFields of struct type t by key.
+func (p *QSUnmarshaler) bindFields(t reflect.Type) (map[string]bindField, error) {
+	if item, ok := p.bindCache.Load(t); ok {
+		if fields, ok := item.(map[string]bindField); ok {
+			return fields, nil
+		}
+		return nil, item.(error)
+	}
+
+	fields := make(map[string]bindField)
+	err := collectBindFields(fields, t, defaultSources, p.opts)
+	if err != nil {
+		p.bindCache.Store(t, err)
+		return nil, err
+	}
+	p.bindCache.Store(t, fields)
+	return fields, nil
+}
+
+// collectBindFields adds the keys of the fields of struct t to fields.
+// The fields of embedded structs are added after the fields of the embedding
+// struct so the outer fields shadow them. The in option of an embedded field
+// sets the default sources of the fields of the embedded struct.
+// It returns an error if the fields of a nested struct have an in option.
+func collectBindFields(fields map[string]bindField, t reflect.Type, sources requestSource, opts *UnmarshalOptions) error {
+	if t.Kind() != reflect.Struct {
+		return &WrongKindError{Expected: reflect.Struct, Actual: t}
+	}
+
+	type embedded struct {
+		Type    reflect.Type
+		Sources requestSource
+	}
+	var embeddedFields []embedded
+	for i, numField := 0, t.NumField(); i < numField; i++ {
+		sf := t.Field(i)
+		skip, tag, err := getStructFieldInfo(sf, tagKey, opts.NameTransformer, MPUnspecified, opts.DefaultUnmarshalPresence)
+		if err != nil {
+			return fmt.Errorf("error creating unmarshaler for field %v of struct %v :: %w", sf.Name, t, err)
+		}
+		if skip {
+			continue
+		}
+		fs := sources
+		if tag.Sources != 0 {
+			fs = tag.Sources
+		}
+		if sf.Anonymous {
+			if isRecursiveType(sf.Type, tagKey) {
+				return fmt.Errorf("error creating unmarshaler for field %v of struct %v :: recursive embedded type: %v",
+					sf.Name, t, sf.Type)
+			}
+			et := sf.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				embeddedFields = append(embeddedFields, embedded{et, fs})
+				continue
+			}
+		}
+		if err := checkNestedBindFields(sf.Type, opts, make(map[reflect.Type]bool)); err != nil {
+			return fmt.Errorf("invalid field %v of struct %v :: %w", sf.Name, t, err)
+		}
+		list := false
+		if um, err := opts.UnmarshalerFactory.Unmarshaler(sf.Type, opts); err == nil {
+			list = isMultiValueUnmarshaler(um)
+		}
+		header := strings.SplitN(sf.Tag.Get(headerTagKey), ",", 2)[0]
+		if header == "" || header == "-" {
+			header = strings.Replace(tag.Name, "_", "-", -1)
+		}
+		if _, ok := fields[tag.Name]; !ok {
+			fields[tag.Name] = bindField{
+				Sources: fs,
+				Header:  textproto.CanonicalMIMEHeaderKey(header),
+				List:    list,
+			}
+		}
+	}
+
+	for _, ef := range embeddedFields {
+		if err := collectBindFields(fields, ef.Type, ef.Sources, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNestedBindFields returns an error if a struct type held by type t
+// (directly or through pointers, arrays, slices and maps) has a field with an
+// in option. The visited map prevents infinite recursion on recursive types.
+func checkNestedBindFields(t reflect.Type, opts *UnmarshalOptions, visited map[reflect.Type]bool) error {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+		return checkNestedBindFields(t.Elem(), opts, visited)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+
+	for i, numField := 0, t.NumField(); i < numField; i++ {
+		sf := t.Field(i)
+		skip, tag, err := getStructFieldInfo(sf, tagKey, opts.NameTransformer, MPUnspecified, opts.DefaultUnmarshalPresence)
+		if err != nil || skip {
+			// Invalid tags are reported by UnmarshalValues.
+			continue
+		}
+		if tag.Sources != 0 {
+			return fmt.Errorf("field %v of nested struct %v can't have an in option", sf.Name, t)
+		}
+		if err := checkNestedBindFields(sf.Type, opts, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeRequestValues merges the values of the sources of r into a single
+// url.Values according to the sources of the fields.
+func mergeRequestValues(r *http.Request, fields map[string]bindField, ks KeySyntax) url.Values {
+	sourcesOf := func(key string) requestSource {
+		if f, ok := fields[key]; ok {
+			return f.Sources
+		}
+		// The keys of nested values start with the key of the field
+		// that holds them followed by a separator of the key syntax.
+		head, _, _ := ks.cut(key)
+		if f, ok := fields[head]; ok {
+			return f.Sources
+		}
+		return defaultSources
+	}
+
+	vs := make(url.Values)
+	for k, a := range r.URL.Query() {
+		if sourcesOf(k)&sourceQuery != 0 {
+			vs[k] = a
+		}
+	}
+	for k, a := range r.PostForm {
+		if sourcesOf(k)&sourceForm != 0 {
+			vs[k] = a
+		}
+	}
+	for k, f := range fields {
+		if f.Sources&sourcePath == 0 {
+			continue
+		}
+		if v := r.PathValue(k); v != "" {
+			vs[k] = []string{v}
+		}
+	}
+	h := canonicalHeader(r.Header)
+	for k, f := range fields {
+		if f.Sources&sourceHeader == 0 {
+			continue
+		}
+		a, ok := h[f.Header]
+		if !ok {
+			continue
+		}
+		if f.List {
+			a = splitHeaderList(a)
+		} else if len(a) > 1 {
+			a = []string{strings.Join(a, ", ")}
+		}
+		vs[k] = a
+	}
+	return vs
+}
@@ -0,0 +1,177 @@
+package qs
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type BPaging struct {
+	Page     int `qs:",default=1"`
+	PageSize int `qs:",in=query"`
+}
+
+type BRequest struct {
+	Name      string    `qs:"name"`
+	Sort      string    `qs:"sort,in=query"`
+	Token     string    `qs:"token,in=form,req"`
+	RequestID string    `qs:"request_id,in=header|query" header:"X-Request-Id"`
+	Tags      []string  `qs:"tags,in=header"`
+	Scopes    *[]string `qs:"scopes,in=header"`
+	Filter    struct {
+		Status string
+	} `qs:"filter,in=query"`
+	Rest url.Values `qs:",remain"`
+	BPaging
+}
+
+func newBindRequest(method, target, body string) *http.Request {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return r
+}
+
+func TestBindRequest(t *testing.T) {
+	r := newBindRequest("POST",
+		"/?name=q&sort=date&token=from-query&request_id=q1&filter.status=open&page=2&page_size=50&utm_source=x",
+		"name=f&sort=from-form&token=t1&filter.status=closed&page_size=5")
+	r.Header.Set("X-Request-Id", "h1")
+	r.Header["tags"] = []string{"a, b", `"c,d"`}
+	r.Header.Set("Scopes", "read, write")
+
+	var v BRequest
+	if err := BindRequest(r, &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cr := &comparisonResults{}
+	cr.compare("Name", v.Name, "f")
+	cr.compare("Sort", v.Sort, "date")
+	cr.compare("Token", v.Token, "t1")
+	cr.compare("RequestID", v.RequestID, "h1")
+	cr.compare("Tags", v.Tags, []string{"a", "b", "c,d"})
+	cr.compare("Scopes != nil", v.Scopes != nil, true)
+	if v.Scopes != nil {
+		cr.compare("Scopes", *v.Scopes, []string{"read", "write"})
+	}
+	cr.compare("Filter.Status", v.Filter.Status, "open")
+	cr.compare("Rest", v.Rest.Encode(), "utm_source=x")
+	cr.compare("Page", v.Page, 2)
+	cr.compare("PageSize", v.PageSize, 50)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+type BPath struct {
+	ID     int    `qs:"id,in=path,req"`
+	Format string `qs:"format"`
+	Name   string `qs:"name,in=query"`
+}
+
+func TestBindRequestPathValues(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items/42/path-name?id=1&format=json&name=q", nil)
+	r.SetPathValue("id", "42")
+	r.SetPathValue("name", "path-name")
+	r.SetPathValue("format", "")
+
+	var v BPath
+	if err := BindRequest(r, &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cr := &comparisonResults{}
+	cr.compare("ID", v.ID, 42)
+	cr.compare("Format", v.Format, "json")
+	cr.compare("Name", v.Name, "q")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBindRequestBracketSyntax(t *testing.T) {
+	type request struct {
+		Filter string     `qs:"filter,in=header"`
+		Rest   url.Values `qs:",remain"`
+	}
+	r := newBindRequest("GET", "/?filter=q&filter.status=open&filter[owner]=me", "")
+	r.Header.Set("Filter", "h")
+
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{KeySyntax: BracketSyntax})
+	var v request
+	if err := unmarshaler.BindRequest(r, &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The dot isn't a separator of BracketSyntax so "filter.status" doesn't
+	// belong to the filter field that is read only from the headers.
+	cr := &comparisonResults{}
+	cr.compare("Filter", v.Filter, "h")
+	cr.compare("Rest", v.Rest.Encode(), "filter.status=open")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBindRequestPresence(t *testing.T) {
+	r := newBindRequest("GET", "/?token=t1&request_id=q1", "")
+
+	var v BRequest
+	err := BindRequest(r, &v)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Key != "token" || !errors.Is(err, ErrRequiredField) {
+		t.Fatalf("got error %v, want a required field error with key token", err)
+	}
+
+	r = newBindRequest("POST", "/", "token=t1")
+	v = BRequest{}
+	if err := BindRequest(r, &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Page", v.Page, 1)
+	cr.compare("RequestID", v.RequestID, "")
+	cr.compare("len(Tags)", len(v.Tags), 0)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBindRequestErrors(t *testing.T) {
+	type invalidIn struct {
+		A string `qs:",in=body"`
+	}
+	type nestedIn struct {
+		Filter *struct {
+			Status string `qs:",in=path"`
+		}
+	}
+	type nestedSliceIn struct {
+		Items []struct {
+			ID string `qs:",in=header"`
+		}
+	}
+	type recursiveEmbedded struct {
+		*recursiveEmbedded
+		Name string
+	}
+
+	r := newBindRequest("GET", "/", "")
+	for _, into := range []interface{}{nil, BRequest{}, &map[string]string{}, &invalidIn{}, &nestedIn{}, &nestedSliceIn{}, &recursiveEmbedded{}} {
+		if err := BindRequest(r, into); err == nil {
+			t.Errorf("BindRequest(%T) succeeded, want error", into)
+		}
+	}
+
+	r = newBindRequest("POST", "/", "token=%zz")
+	if err := BindRequest(r, &BRequest{}); err == nil {
+		t.Error("BindRequest succeeded with an invalid body, want error")
+	}
+}
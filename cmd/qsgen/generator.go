@@ -0,0 +1,525 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pasztorpisti/qs/internal/tagparse"
+)
+
+const qsImportPath = "github.com/pasztorpisti/qs"
+
+// fieldKind tells how the primitive value of a field is stored.
+type fieldKind int
+
+const (
+	scalarField fieldKind = iota
+	ptrField
+	sliceField
+)
+
+// field describes a struct field for the code generator.
+type field struct {
+	// Name is the name of the field in the Go struct.
+	Name string
+	// Key is the key of the field in the query string.
+	Key  string
+	Kind fieldKind
+	// TypeExpr is the type of the field in Go syntax.
+	TypeExpr string
+	// ElemExpr is the type of the primitive value in Go syntax: the type of
+	// the field, the element type of the pointer or slice.
+	ElemExpr string
+	Elem     *types.Basic
+	// MarshalPresence is the name of the qs.MarshalPresence constant
+	// specified in the field tag. It is empty if the tag doesn't specify it.
+	MarshalPresence string
+	// UnmarshalPresence is the name of the qs.UnmarshalPresence constant
+	// specified in the field tag. It is empty if the tag doesn't specify it.
+	UnmarshalPresence string
+}
+
+// structType is a struct type for which the methods are generated.
+type structType struct {
+	Name   string
+	Fields []*field
+}
+
+// generate parses the Go package in dir and returns the formatted source of
+// the file that contains the generated methods of the given struct types.
+// The file with the name skipFile (the previously generated output) is
+// excluded from the parsing.
+func generate(dir string, typeNames []string, skipFile string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		name := fi.Name()
+		return !strings.HasSuffix(name, "_test.go") && name != skipFile
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected exactly one package in directory %q, found %v", dir, len(pkgs))
+	}
+	var files []*ast.File
+	var pkgName string
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+	}
+
+	// The errors are ignored because the package may refer to previously
+	// generated methods that are missing until the generation finishes.
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	pkg, _ := conf.Check(pkgName, fset, files, nil)
+
+	var sts []*structType
+	for _, name := range typeNames {
+		st, err := newStructType(pkg, strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		sts = append(sts, st)
+	}
+
+	src := writeFile(pkgName, sts)
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("error formatting generated code :: %w\n%s", err, src)
+	}
+	return formatted, nil
+}
+
+func newStructType(pkg *types.Package, name string) (*structType, error) {
+	obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("type %v not found", name)
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("%v isn't a defined type", name)
+	}
+	s, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%v isn't a struct type", name)
+	}
+
+	st := &structType{Name: name}
+	for i := 0; i < s.NumFields(); i++ {
+		v := s.Field(i)
+		if v.Embedded() {
+			return nil, fmt.Errorf("embedded field %v of struct %v isn't supported", v.Name(), name)
+		}
+		// Skipping unexported fields.
+		if !v.Exported() {
+			continue
+		}
+		f, err := newField(pkg, v, reflect.StructTag(s.Tag(i)))
+		if err != nil {
+			return nil, fmt.Errorf("field %v of struct %v :: %w", v.Name(), name, err)
+		}
+		if f != nil {
+			st.Fields = append(st.Fields, f)
+		}
+	}
+	return st, nil
+}
+
+// newField returns nil if the field is skipped by the "-" name in its tag.
+func newField(pkg *types.Package, v *types.Var, tag reflect.StructTag) (*field, error) {
+	f := &field{Name: v.Name()}
+	if err := f.parseTag(tag); err != nil {
+		return nil, fmt.Errorf("invalid tag: %q :: %w", tag, err)
+	}
+	if f.Key == "-" {
+		return nil, nil
+	}
+	if f.Key == "" {
+		f.Key = tagparse.SnakeCase(f.Name)
+	}
+
+	qualifier := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+
+	t := v.Type()
+	if err := checkNoMethods(pkg, t); err != nil {
+		return nil, err
+	}
+	f.TypeExpr = types.TypeString(t, qualifier)
+	et := t
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		if _, ok := t.(*types.Named); ok {
+			return nil, fmt.Errorf("unsupported named pointer type: %v", f.TypeExpr)
+		}
+		f.Kind = ptrField
+		et = u.Elem()
+	case *types.Slice:
+		f.Kind = sliceField
+		et = u.Elem()
+	}
+	if f.Kind != scalarField {
+		if err := checkNoMethods(pkg, et); err != nil {
+			return nil, err
+		}
+	}
+
+	b, ok := et.Underlying().(*types.Basic)
+	if !ok || !isSupportedBasic(b) {
+		return nil, fmt.Errorf("unsupported type: %v", f.TypeExpr)
+	}
+	f.Elem = b
+	f.ElemExpr = types.TypeString(et, qualifier)
+	return f, nil
+}
+
+// checkNoMethods returns an error if t isn't defined in pkg or if it
+// implements any of the interfaces that customize the marshaling of a type.
+func checkNoMethods(pkg *types.Package, t types.Type) error {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil
+	}
+	if obj := named.Obj(); obj.Pkg() != pkg {
+		return fmt.Errorf("unsupported type defined in another package: %v", t)
+	}
+	ms := types.NewMethodSet(types.NewPointer(t))
+	for _, m := range []string{"MarshalQS", "UnmarshalQS", "MarshalText", "UnmarshalText"} {
+		if ms.Lookup(pkg, m) != nil {
+			return fmt.Errorf("unsupported type with a %v method: %v", m, named.Obj().Name())
+		}
+	}
+	return nil
+}
+
+func isSupportedBasic(b *types.Basic) bool {
+	switch b.Kind() {
+	case types.String, types.Bool,
+		types.Int, types.Int8, types.Int16, types.Int32, types.Int64,
+		types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64,
+		types.Float32, types.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseTag parses the tag with the parser of the qs package but accepts only
+// the options that are supported by the generated code.
+func (f *field) parseTag(tag reflect.StructTag) error {
+	t, err := tagparse.Parse(tag.Get("qs"))
+	if err != nil {
+		return err
+	}
+	f.Key = t.Name
+	for _, option := range t.Options {
+		switch option {
+		case "nil", "opt", "req", "keepempty", "omitempty":
+		default:
+			return fmt.Errorf("option isn't supported by qsgen: %q", option)
+		}
+	}
+	f.MarshalPresence = presenceNames[t.MarshalPresence]
+	f.UnmarshalPresence = presenceNames[t.UnmarshalPresence]
+	return nil
+}
+
+// presenceNames maps the presence tag options to the names of the
+// qs.MarshalPresence and qs.UnmarshalPresence constants.
+var presenceNames = map[string]string{
+	"nil":       "Nil",
+	"opt":       "Opt",
+	"req":       "Req",
+	"keepempty": "KeepEmpty",
+	"omitempty": "OmitEmpty",
+}
+
+// writer accumulates the generated code and the imports it needs.
+type writer struct {
+	bytes.Buffer
+	imports map[string]bool
+}
+
+func (w *writer) p(format string, args ...interface{}) {
+	fmt.Fprintf(w, format, args...)
+	w.WriteByte('\n')
+}
+
+func writeFile(pkgName string, sts []*structType) []byte {
+	w := &writer{imports: map[string]bool{"net/url": true, qsImportPath: true}}
+	for _, st := range sts {
+		w.writeMarshal(st)
+		w.writeUnmarshal(st)
+	}
+
+	// The standard library imports are followed by the qs package.
+	var imports []string
+	for imp := range w.imports {
+		if imp != qsImportPath {
+			imports = append(imports, strconv.Quote(imp))
+		}
+	}
+	sort.Strings(imports)
+	imports = append(imports, "", strconv.Quote(qsImportPath))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by qsgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %v\n\n", pkgName)
+	fmt.Fprintf(&buf, "import (\n%v\n)\n", strings.Join(imports, "\n"))
+	buf.Write(w.Bytes())
+	return buf.Bytes()
+}
+
+func (w *writer) writeMarshal(st *structType) {
+	w.p("")
+	w.p("// MarshalQSValues implements the qs.MarshalQSValues interface.")
+	w.p("func (p *%v) MarshalQSValues(opts *qs.MarshalOptions) (url.Values, error) {", st.Name)
+	w.p("vs := make(url.Values, %v)", len(st.Fields))
+	for _, f := range st.Fields {
+		target := "p." + f.Name
+		switch f.Kind {
+		case scalarField:
+			var cond string
+			switch f.MarshalPresence {
+			case "KeepEmpty":
+			case "OmitEmpty":
+				cond = nonEmptyExpr(target, f)
+			default:
+				cond = "opts.DefaultMarshalPresence == qs.KeepEmpty || " + nonEmptyExpr(target, f)
+			}
+			if cond != "" {
+				w.p("if %v {", cond)
+			}
+			w.p("vs[%q] = []string{%v}", f.Key, w.formatExpr(target, f))
+			if cond != "" {
+				w.p("}")
+			}
+		case ptrField:
+			w.p("if %v != nil {", target)
+			w.p("vs[%q] = []string{%v}", f.Key, w.formatExpr("*"+target, f))
+			w.p("}")
+		case sliceField:
+			w.p("if len(%v) != 0 {", target)
+			w.p("a := make([]string, len(%v))", target)
+			w.p("for i, item := range %v {", target)
+			w.p("a[i] = %v", w.formatExpr("item", f))
+			w.p("}")
+			w.p("vs[%q] = a", f.Key)
+			w.p("}")
+		}
+	}
+	w.p("return vs, nil")
+	w.p("}")
+}
+
+func nonEmptyExpr(v string, f *field) string {
+	switch f.Elem.Kind() {
+	case types.String:
+		return v + ` != ""`
+	case types.Bool:
+		return f.convert("bool", v)
+	default:
+		return v + " != 0"
+	}
+}
+
+// formatExpr returns an expression that formats the value v the same way as
+// the builtin marshalers of the qs package.
+func (w *writer) formatExpr(v string, f *field) string {
+	if f.Elem.Kind() != types.String {
+		w.imports["strconv"] = true
+	}
+	switch f.Elem.Kind() {
+	case types.String:
+		return f.convert("string", v)
+	case types.Bool:
+		return "strconv.FormatBool(" + f.convert("bool", v) + ")"
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		return "strconv.FormatInt(" + f.convert("int64", v) + ", 10)"
+	case types.Float32:
+		return "strconv.FormatFloat(float64(" + v + "), 'f', -1, 32)"
+	case types.Float64:
+		return "strconv.FormatFloat(" + f.convert("float64", v) + ", 'f', -1, 64)"
+	default:
+		return "strconv.FormatUint(" + f.convert("uint64", v) + ", 10)"
+	}
+}
+
+// convert returns the expression that converts the primitive value v of
+// the field to the type typ. The conversion is omitted if v already has that
+// type.
+func (f *field) convert(typ, v string) string {
+	if f.ElemExpr == typ {
+		return v
+	}
+	return typ + "(" + v + ")"
+}
+
+func (w *writer) writeUnmarshal(st *structType) {
+	w.p("")
+	w.p("// UnmarshalQSValues implements the qs.UnmarshalQSValues interface.")
+	w.p("func (p *%v) UnmarshalQSValues(vs url.Values, opts *qs.UnmarshalOptions) error {", st.Name)
+	for _, f := range st.Fields {
+		target := "p." + f.Name
+
+		// reqCond is the condition of reporting a missing field as an
+		// error, optCond is the condition of initializing the pointer and
+		// slice fields of missing keys.
+		reqCond, optCond := "false", "false"
+		switch f.UnmarshalPresence {
+		case "Req":
+			reqCond = "true"
+		case "Opt":
+			optCond = "true"
+		case "":
+			reqCond = "opts.DefaultUnmarshalPresence == qs.Req"
+			optCond = "opts.DefaultUnmarshalPresence == qs.Opt"
+		}
+		if f.Kind == scalarField {
+			optCond = "false"
+		}
+
+		switch optCond {
+		case "true":
+			w.p("{")
+			w.p("a := vs[%q]", f.Key)
+		case "false":
+			w.p("if a, ok := vs[%q]; ok {", f.Key)
+		default:
+			w.p("if a, ok := vs[%q]; ok || %v {", f.Key, optCond)
+		}
+		fieldErr := func(errExpr string) string {
+			return fmt.Sprintf("&qs.FieldError{Field: %q, Key: %q, Values: a, Err: %v}", f.Name, f.Key, errExpr)
+		}
+		switch f.Kind {
+		case scalarField:
+			w.p("if a != nil {")
+			w.writeParse(target, "a", f, fieldErr)
+			w.p("}")
+		case ptrField:
+			w.p("if %v == nil {", target)
+			w.p("%v = new(%v)", target, f.ElemExpr)
+			w.p("}")
+			w.p("if a != nil {")
+			w.writeParse("*"+target, "a", f, fieldErr)
+			w.p("}")
+		case sliceField:
+			w.imports["fmt"] = true
+			w.p("if %v == nil {", target)
+			w.p("%v = make(%v, len(a))", target, f.TypeExpr)
+			w.p("}")
+			w.p("for i := range a {")
+			w.writeParse(target+"[i]", "a[i:i+1]", f, func(errExpr string) string {
+				return fieldErr(`fmt.Errorf("error unmarshaling slice index %v :: %w", i, ` + errExpr + ")")
+			})
+			w.p("}")
+		}
+		switch reqCond {
+		case "false":
+			w.p("}")
+		case "true":
+			w.p("} else {")
+		default:
+			w.p("} else if %v {", reqCond)
+		}
+		if reqCond != "false" {
+			w.p("return &qs.FieldError{Field: %q, Key: %q, Err: qs.ErrRequiredField}", f.Name, f.Key)
+			w.p("}")
+		}
+	}
+	w.p("return nil")
+	w.p("}")
+}
+
+// writeParse writes the code that unmarshals the []string src into target
+// the same way as the builtin unmarshalers of the qs package.
+func (w *writer) writeParse(target, src string, f *field, fieldErr func(errExpr string) string) {
+	w.p("s, err := opts.SliceToString(%v)", src)
+	w.p("if err != nil {")
+	w.p("return %v", fieldErr("err"))
+	w.p("}")
+
+	var parse string
+	switch b := f.Elem; b.Kind() {
+	case types.String:
+		w.p("%v = %v", target, f.convertFrom("string", "s"))
+		return
+	case types.Bool:
+		parse = "strconv.ParseBool(s)"
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		parse = fmt.Sprintf("strconv.ParseInt(s, 0, %v)", bitSize(b))
+	case types.Float32, types.Float64:
+		parse = fmt.Sprintf("strconv.ParseFloat(s, %v)", bitSize(b))
+	default:
+		parse = fmt.Sprintf("strconv.ParseUint(s, 0, %v)", bitSize(b))
+	}
+	w.imports["strconv"] = true
+	w.p("v, err := %v", parse)
+	w.p("if err != nil {")
+	w.p("return %v", fieldErr("err"))
+	w.p("}")
+	w.p("%v = %v", target, f.convertFrom(parsedType(f.Elem), "v"))
+}
+
+// convertFrom returns the expression that converts the value v of type typ
+// to the type of the primitive value of the field.
+func (f *field) convertFrom(typ, v string) string {
+	if f.ElemExpr == typ {
+		return v
+	}
+	return f.ElemExpr + "(" + v + ")"
+}
+
+// parsedType returns the type of the value returned by the strconv parser
+// function of b.
+func parsedType(b *types.Basic) string {
+	switch b.Kind() {
+	case types.String:
+		return "string"
+	case types.Bool:
+		return "bool"
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		return "int64"
+	case types.Float32, types.Float64:
+		return "float64"
+	default:
+		return "uint64"
+	}
+}
+
+// bitSize returns the bitSize parameter of the strconv parser functions.
+// Zero means the size of int and uint.
+func bitSize(b *types.Basic) int {
+	switch b.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Int64, types.Uint64, types.Float64:
+		return 64
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,72 @@
+// Package corpus holds the struct types that are used to test that the code
+// generated by qsgen marshals and unmarshals exactly like the reflection based
+// path of the qs package.
+package corpus
+
+//go:generate go run ../.. -type Scalars,Pointers,Slices,Presence -output corpus_qs.go
+
+// Color is a named type with a string underlying type.
+type Color string
+
+// Level is a named type with an integer underlying type.
+type Level int8
+
+type Scalars struct {
+	Name       string
+	Enabled    bool
+	Int        int
+	Int8       int8
+	Int16      int16
+	Int32      int32
+	Int64      int64
+	Uint       uint
+	Uint8      uint8
+	Uint16     uint16
+	Uint32     uint32
+	Uint64     uint64
+	Float32    float32
+	Float64    float64
+	Color      Color
+	Level      Level
+	IDs        string
+	HTTPServer string
+	Snake_case string
+	Explicit   string `qs:"explicit_NAME"`
+	Skipped    string `qs:"-"`
+	unexported string
+}
+
+type Pointers struct {
+	Name    *string
+	Enabled *bool
+	Int     *int
+	Uint16  *uint16
+	Float32 *float32
+	Color   *Color
+	Opt     *int    `qs:",opt"`
+	Nil     *string `qs:",nil"`
+	Req     *int64  `qs:",req"`
+}
+
+type Slices struct {
+	Names   []string
+	Flags   []bool
+	Ints    []int
+	Bytes   []byte
+	Floats  []float64
+	Colors  []Color
+	Levels  []Level `qs:"lvl"`
+	Opt     []int   `qs:",opt"`
+	Nil     []int   `qs:",nil"`
+	Req     []uint  `qs:",req"`
+	OmitAll []int   `qs:",omitempty"`
+}
+
+type Presence struct {
+	Keep      string  `qs:",keepempty"`
+	OmitEmpty int     `qs:",omitempty"`
+	Opt       string  `qs:",opt"`
+	Nil       float64 `qs:",nil"`
+	Req       uint8   `qs:",req"`
+	KeepReq   string  `qs:"keep_req,keepempty,req"`
+}
@@ -0,0 +1,917 @@
+// Code generated by qsgen. DO NOT EDIT.
+
+package corpus
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/pasztorpisti/qs"
+)
+
+// MarshalQSValues implements the qs.MarshalQSValues interface.
+func (p *Scalars) MarshalQSValues(opts *qs.MarshalOptions) (url.Values, error) {
+	vs := make(url.Values, 20)
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Name != "" {
+		vs["name"] = []string{p.Name}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Enabled {
+		vs["enabled"] = []string{strconv.FormatBool(p.Enabled)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Int != 0 {
+		vs["int"] = []string{strconv.FormatInt(int64(p.Int), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Int8 != 0 {
+		vs["int8"] = []string{strconv.FormatInt(int64(p.Int8), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Int16 != 0 {
+		vs["int16"] = []string{strconv.FormatInt(int64(p.Int16), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Int32 != 0 {
+		vs["int32"] = []string{strconv.FormatInt(int64(p.Int32), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Int64 != 0 {
+		vs["int64"] = []string{strconv.FormatInt(p.Int64, 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Uint != 0 {
+		vs["uint"] = []string{strconv.FormatUint(uint64(p.Uint), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Uint8 != 0 {
+		vs["uint8"] = []string{strconv.FormatUint(uint64(p.Uint8), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Uint16 != 0 {
+		vs["uint16"] = []string{strconv.FormatUint(uint64(p.Uint16), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Uint32 != 0 {
+		vs["uint32"] = []string{strconv.FormatUint(uint64(p.Uint32), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Uint64 != 0 {
+		vs["uint64"] = []string{strconv.FormatUint(p.Uint64, 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Float32 != 0 {
+		vs["float32"] = []string{strconv.FormatFloat(float64(p.Float32), 'f', -1, 32)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Float64 != 0 {
+		vs["float64"] = []string{strconv.FormatFloat(p.Float64, 'f', -1, 64)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Color != "" {
+		vs["color"] = []string{string(p.Color)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Level != 0 {
+		vs["level"] = []string{strconv.FormatInt(int64(p.Level), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.IDs != "" {
+		vs["i_ds"] = []string{p.IDs}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.HTTPServer != "" {
+		vs["http_server"] = []string{p.HTTPServer}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Snake_case != "" {
+		vs["snake_case"] = []string{p.Snake_case}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Explicit != "" {
+		vs["explicit_NAME"] = []string{p.Explicit}
+	}
+	return vs, nil
+}
+
+// UnmarshalQSValues implements the qs.UnmarshalQSValues interface.
+func (p *Scalars) UnmarshalQSValues(vs url.Values, opts *qs.UnmarshalOptions) error {
+	if a, ok := vs["name"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Name", Key: "name", Values: a, Err: err}
+			}
+			p.Name = s
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Name", Key: "name", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["enabled"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Enabled", Key: "enabled", Values: a, Err: err}
+			}
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				return &qs.FieldError{Field: "Enabled", Key: "enabled", Values: a, Err: err}
+			}
+			p.Enabled = v
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Enabled", Key: "enabled", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["int"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Int", Key: "int", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "Int", Key: "int", Values: a, Err: err}
+			}
+			p.Int = int(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Int", Key: "int", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["int8"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Int8", Key: "int8", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 8)
+			if err != nil {
+				return &qs.FieldError{Field: "Int8", Key: "int8", Values: a, Err: err}
+			}
+			p.Int8 = int8(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Int8", Key: "int8", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["int16"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Int16", Key: "int16", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 16)
+			if err != nil {
+				return &qs.FieldError{Field: "Int16", Key: "int16", Values: a, Err: err}
+			}
+			p.Int16 = int16(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Int16", Key: "int16", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["int32"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Int32", Key: "int32", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 32)
+			if err != nil {
+				return &qs.FieldError{Field: "Int32", Key: "int32", Values: a, Err: err}
+			}
+			p.Int32 = int32(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Int32", Key: "int32", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["int64"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Int64", Key: "int64", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 64)
+			if err != nil {
+				return &qs.FieldError{Field: "Int64", Key: "int64", Values: a, Err: err}
+			}
+			p.Int64 = v
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Int64", Key: "int64", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["uint"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint", Key: "uint", Values: a, Err: err}
+			}
+			v, err := strconv.ParseUint(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint", Key: "uint", Values: a, Err: err}
+			}
+			p.Uint = uint(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Uint", Key: "uint", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["uint8"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint8", Key: "uint8", Values: a, Err: err}
+			}
+			v, err := strconv.ParseUint(s, 0, 8)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint8", Key: "uint8", Values: a, Err: err}
+			}
+			p.Uint8 = uint8(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Uint8", Key: "uint8", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["uint16"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint16", Key: "uint16", Values: a, Err: err}
+			}
+			v, err := strconv.ParseUint(s, 0, 16)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint16", Key: "uint16", Values: a, Err: err}
+			}
+			p.Uint16 = uint16(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Uint16", Key: "uint16", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["uint32"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint32", Key: "uint32", Values: a, Err: err}
+			}
+			v, err := strconv.ParseUint(s, 0, 32)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint32", Key: "uint32", Values: a, Err: err}
+			}
+			p.Uint32 = uint32(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Uint32", Key: "uint32", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["uint64"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint64", Key: "uint64", Values: a, Err: err}
+			}
+			v, err := strconv.ParseUint(s, 0, 64)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint64", Key: "uint64", Values: a, Err: err}
+			}
+			p.Uint64 = v
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Uint64", Key: "uint64", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["float32"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Float32", Key: "float32", Values: a, Err: err}
+			}
+			v, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return &qs.FieldError{Field: "Float32", Key: "float32", Values: a, Err: err}
+			}
+			p.Float32 = float32(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Float32", Key: "float32", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["float64"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Float64", Key: "float64", Values: a, Err: err}
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return &qs.FieldError{Field: "Float64", Key: "float64", Values: a, Err: err}
+			}
+			p.Float64 = v
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Float64", Key: "float64", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["color"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Color", Key: "color", Values: a, Err: err}
+			}
+			p.Color = Color(s)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Color", Key: "color", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["level"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Level", Key: "level", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 8)
+			if err != nil {
+				return &qs.FieldError{Field: "Level", Key: "level", Values: a, Err: err}
+			}
+			p.Level = Level(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Level", Key: "level", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["i_ds"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "IDs", Key: "i_ds", Values: a, Err: err}
+			}
+			p.IDs = s
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "IDs", Key: "i_ds", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["http_server"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "HTTPServer", Key: "http_server", Values: a, Err: err}
+			}
+			p.HTTPServer = s
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "HTTPServer", Key: "http_server", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["snake_case"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Snake_case", Key: "snake_case", Values: a, Err: err}
+			}
+			p.Snake_case = s
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Snake_case", Key: "snake_case", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["explicit_NAME"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Explicit", Key: "explicit_NAME", Values: a, Err: err}
+			}
+			p.Explicit = s
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Explicit", Key: "explicit_NAME", Err: qs.ErrRequiredField}
+	}
+	return nil
+}
+
+// MarshalQSValues implements the qs.MarshalQSValues interface.
+func (p *Pointers) MarshalQSValues(opts *qs.MarshalOptions) (url.Values, error) {
+	vs := make(url.Values, 9)
+	if p.Name != nil {
+		vs["name"] = []string{*p.Name}
+	}
+	if p.Enabled != nil {
+		vs["enabled"] = []string{strconv.FormatBool(*p.Enabled)}
+	}
+	if p.Int != nil {
+		vs["int"] = []string{strconv.FormatInt(int64(*p.Int), 10)}
+	}
+	if p.Uint16 != nil {
+		vs["uint16"] = []string{strconv.FormatUint(uint64(*p.Uint16), 10)}
+	}
+	if p.Float32 != nil {
+		vs["float32"] = []string{strconv.FormatFloat(float64(*p.Float32), 'f', -1, 32)}
+	}
+	if p.Color != nil {
+		vs["color"] = []string{string(*p.Color)}
+	}
+	if p.Opt != nil {
+		vs["opt"] = []string{strconv.FormatInt(int64(*p.Opt), 10)}
+	}
+	if p.Nil != nil {
+		vs["nil"] = []string{*p.Nil}
+	}
+	if p.Req != nil {
+		vs["req"] = []string{strconv.FormatInt(*p.Req, 10)}
+	}
+	return vs, nil
+}
+
+// UnmarshalQSValues implements the qs.UnmarshalQSValues interface.
+func (p *Pointers) UnmarshalQSValues(vs url.Values, opts *qs.UnmarshalOptions) error {
+	if a, ok := vs["name"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Name == nil {
+			p.Name = new(string)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Name", Key: "name", Values: a, Err: err}
+			}
+			*p.Name = s
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Name", Key: "name", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["enabled"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Enabled == nil {
+			p.Enabled = new(bool)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Enabled", Key: "enabled", Values: a, Err: err}
+			}
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				return &qs.FieldError{Field: "Enabled", Key: "enabled", Values: a, Err: err}
+			}
+			*p.Enabled = v
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Enabled", Key: "enabled", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["int"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Int == nil {
+			p.Int = new(int)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Int", Key: "int", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "Int", Key: "int", Values: a, Err: err}
+			}
+			*p.Int = int(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Int", Key: "int", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["uint16"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Uint16 == nil {
+			p.Uint16 = new(uint16)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint16", Key: "uint16", Values: a, Err: err}
+			}
+			v, err := strconv.ParseUint(s, 0, 16)
+			if err != nil {
+				return &qs.FieldError{Field: "Uint16", Key: "uint16", Values: a, Err: err}
+			}
+			*p.Uint16 = uint16(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Uint16", Key: "uint16", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["float32"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Float32 == nil {
+			p.Float32 = new(float32)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Float32", Key: "float32", Values: a, Err: err}
+			}
+			v, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return &qs.FieldError{Field: "Float32", Key: "float32", Values: a, Err: err}
+			}
+			*p.Float32 = float32(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Float32", Key: "float32", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["color"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Color == nil {
+			p.Color = new(Color)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Color", Key: "color", Values: a, Err: err}
+			}
+			*p.Color = Color(s)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Color", Key: "color", Err: qs.ErrRequiredField}
+	}
+	{
+		a := vs["opt"]
+		if p.Opt == nil {
+			p.Opt = new(int)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Opt", Key: "opt", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "Opt", Key: "opt", Values: a, Err: err}
+			}
+			*p.Opt = int(v)
+		}
+	}
+	if a, ok := vs["nil"]; ok {
+		if p.Nil == nil {
+			p.Nil = new(string)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Nil", Key: "nil", Values: a, Err: err}
+			}
+			*p.Nil = s
+		}
+	}
+	if a, ok := vs["req"]; ok {
+		if p.Req == nil {
+			p.Req = new(int64)
+		}
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Req", Key: "req", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 64)
+			if err != nil {
+				return &qs.FieldError{Field: "Req", Key: "req", Values: a, Err: err}
+			}
+			*p.Req = v
+		}
+	} else {
+		return &qs.FieldError{Field: "Req", Key: "req", Err: qs.ErrRequiredField}
+	}
+	return nil
+}
+
+// MarshalQSValues implements the qs.MarshalQSValues interface.
+func (p *Slices) MarshalQSValues(opts *qs.MarshalOptions) (url.Values, error) {
+	vs := make(url.Values, 11)
+	if len(p.Names) != 0 {
+		a := make([]string, len(p.Names))
+		for i, item := range p.Names {
+			a[i] = item
+		}
+		vs["names"] = a
+	}
+	if len(p.Flags) != 0 {
+		a := make([]string, len(p.Flags))
+		for i, item := range p.Flags {
+			a[i] = strconv.FormatBool(item)
+		}
+		vs["flags"] = a
+	}
+	if len(p.Ints) != 0 {
+		a := make([]string, len(p.Ints))
+		for i, item := range p.Ints {
+			a[i] = strconv.FormatInt(int64(item), 10)
+		}
+		vs["ints"] = a
+	}
+	if len(p.Bytes) != 0 {
+		a := make([]string, len(p.Bytes))
+		for i, item := range p.Bytes {
+			a[i] = strconv.FormatUint(uint64(item), 10)
+		}
+		vs["bytes"] = a
+	}
+	if len(p.Floats) != 0 {
+		a := make([]string, len(p.Floats))
+		for i, item := range p.Floats {
+			a[i] = strconv.FormatFloat(item, 'f', -1, 64)
+		}
+		vs["floats"] = a
+	}
+	if len(p.Colors) != 0 {
+		a := make([]string, len(p.Colors))
+		for i, item := range p.Colors {
+			a[i] = string(item)
+		}
+		vs["colors"] = a
+	}
+	if len(p.Levels) != 0 {
+		a := make([]string, len(p.Levels))
+		for i, item := range p.Levels {
+			a[i] = strconv.FormatInt(int64(item), 10)
+		}
+		vs["lvl"] = a
+	}
+	if len(p.Opt) != 0 {
+		a := make([]string, len(p.Opt))
+		for i, item := range p.Opt {
+			a[i] = strconv.FormatInt(int64(item), 10)
+		}
+		vs["opt"] = a
+	}
+	if len(p.Nil) != 0 {
+		a := make([]string, len(p.Nil))
+		for i, item := range p.Nil {
+			a[i] = strconv.FormatInt(int64(item), 10)
+		}
+		vs["nil"] = a
+	}
+	if len(p.Req) != 0 {
+		a := make([]string, len(p.Req))
+		for i, item := range p.Req {
+			a[i] = strconv.FormatUint(uint64(item), 10)
+		}
+		vs["req"] = a
+	}
+	if len(p.OmitAll) != 0 {
+		a := make([]string, len(p.OmitAll))
+		for i, item := range p.OmitAll {
+			a[i] = strconv.FormatInt(int64(item), 10)
+		}
+		vs["omit_all"] = a
+	}
+	return vs, nil
+}
+
+// UnmarshalQSValues implements the qs.UnmarshalQSValues interface.
+func (p *Slices) UnmarshalQSValues(vs url.Values, opts *qs.UnmarshalOptions) error {
+	if a, ok := vs["names"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Names == nil {
+			p.Names = make([]string, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Names", Key: "names", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Names[i] = s
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Names", Key: "names", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["flags"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Flags == nil {
+			p.Flags = make([]bool, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Flags", Key: "flags", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				return &qs.FieldError{Field: "Flags", Key: "flags", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Flags[i] = v
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Flags", Key: "flags", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["ints"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Ints == nil {
+			p.Ints = make([]int, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Ints", Key: "ints", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseInt(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "Ints", Key: "ints", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Ints[i] = int(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Ints", Key: "ints", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["bytes"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Bytes == nil {
+			p.Bytes = make([]byte, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Bytes", Key: "bytes", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseUint(s, 0, 8)
+			if err != nil {
+				return &qs.FieldError{Field: "Bytes", Key: "bytes", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Bytes[i] = byte(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Bytes", Key: "bytes", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["floats"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Floats == nil {
+			p.Floats = make([]float64, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Floats", Key: "floats", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return &qs.FieldError{Field: "Floats", Key: "floats", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Floats[i] = v
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Floats", Key: "floats", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["colors"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Colors == nil {
+			p.Colors = make([]Color, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Colors", Key: "colors", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Colors[i] = Color(s)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Colors", Key: "colors", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["lvl"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.Levels == nil {
+			p.Levels = make([]Level, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Levels", Key: "lvl", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseInt(s, 0, 8)
+			if err != nil {
+				return &qs.FieldError{Field: "Levels", Key: "lvl", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Levels[i] = Level(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Levels", Key: "lvl", Err: qs.ErrRequiredField}
+	}
+	{
+		a := vs["opt"]
+		if p.Opt == nil {
+			p.Opt = make([]int, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Opt", Key: "opt", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseInt(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "Opt", Key: "opt", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Opt[i] = int(v)
+		}
+	}
+	if a, ok := vs["nil"]; ok {
+		if p.Nil == nil {
+			p.Nil = make([]int, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Nil", Key: "nil", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseInt(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "Nil", Key: "nil", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Nil[i] = int(v)
+		}
+	}
+	if a, ok := vs["req"]; ok {
+		if p.Req == nil {
+			p.Req = make([]uint, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "Req", Key: "req", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseUint(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "Req", Key: "req", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.Req[i] = uint(v)
+		}
+	} else {
+		return &qs.FieldError{Field: "Req", Key: "req", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["omit_all"]; ok || opts.DefaultUnmarshalPresence == qs.Opt {
+		if p.OmitAll == nil {
+			p.OmitAll = make([]int, len(a))
+		}
+		for i := range a {
+			s, err := opts.SliceToString(a[i : i+1])
+			if err != nil {
+				return &qs.FieldError{Field: "OmitAll", Key: "omit_all", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			v, err := strconv.ParseInt(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "OmitAll", Key: "omit_all", Values: a, Err: fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)}
+			}
+			p.OmitAll[i] = int(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "OmitAll", Key: "omit_all", Err: qs.ErrRequiredField}
+	}
+	return nil
+}
+
+// MarshalQSValues implements the qs.MarshalQSValues interface.
+func (p *Presence) MarshalQSValues(opts *qs.MarshalOptions) (url.Values, error) {
+	vs := make(url.Values, 6)
+	vs["keep"] = []string{p.Keep}
+	if p.OmitEmpty != 0 {
+		vs["omit_empty"] = []string{strconv.FormatInt(int64(p.OmitEmpty), 10)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Opt != "" {
+		vs["opt"] = []string{p.Opt}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Nil != 0 {
+		vs["nil"] = []string{strconv.FormatFloat(p.Nil, 'f', -1, 64)}
+	}
+	if opts.DefaultMarshalPresence == qs.KeepEmpty || p.Req != 0 {
+		vs["req"] = []string{strconv.FormatUint(uint64(p.Req), 10)}
+	}
+	vs["keep_req"] = []string{p.KeepReq}
+	return vs, nil
+}
+
+// UnmarshalQSValues implements the qs.UnmarshalQSValues interface.
+func (p *Presence) UnmarshalQSValues(vs url.Values, opts *qs.UnmarshalOptions) error {
+	if a, ok := vs["keep"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Keep", Key: "keep", Values: a, Err: err}
+			}
+			p.Keep = s
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "Keep", Key: "keep", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["omit_empty"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "OmitEmpty", Key: "omit_empty", Values: a, Err: err}
+			}
+			v, err := strconv.ParseInt(s, 0, 0)
+			if err != nil {
+				return &qs.FieldError{Field: "OmitEmpty", Key: "omit_empty", Values: a, Err: err}
+			}
+			p.OmitEmpty = int(v)
+		}
+	} else if opts.DefaultUnmarshalPresence == qs.Req {
+		return &qs.FieldError{Field: "OmitEmpty", Key: "omit_empty", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["opt"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Opt", Key: "opt", Values: a, Err: err}
+			}
+			p.Opt = s
+		}
+	}
+	if a, ok := vs["nil"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Nil", Key: "nil", Values: a, Err: err}
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return &qs.FieldError{Field: "Nil", Key: "nil", Values: a, Err: err}
+			}
+			p.Nil = v
+		}
+	}
+	if a, ok := vs["req"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "Req", Key: "req", Values: a, Err: err}
+			}
+			v, err := strconv.ParseUint(s, 0, 8)
+			if err != nil {
+				return &qs.FieldError{Field: "Req", Key: "req", Values: a, Err: err}
+			}
+			p.Req = uint8(v)
+		}
+	} else {
+		return &qs.FieldError{Field: "Req", Key: "req", Err: qs.ErrRequiredField}
+	}
+	if a, ok := vs["keep_req"]; ok {
+		if a != nil {
+			s, err := opts.SliceToString(a)
+			if err != nil {
+				return &qs.FieldError{Field: "KeepReq", Key: "keep_req", Values: a, Err: err}
+			}
+			p.KeepReq = s
+		}
+	} else {
+		return &qs.FieldError{Field: "KeepReq", Key: "keep_req", Err: qs.ErrRequiredField}
+	}
+	return nil
+}
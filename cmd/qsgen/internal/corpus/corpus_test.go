@@ -0,0 +1,260 @@
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/pasztorpisti/qs"
+)
+
+// The plain types have the same fields as the corpus types but they don't
+// have the generated methods so they are handled by the reflection based path
+// of the qs package.
+type (
+	plainScalars  Scalars
+	plainPointers Pointers
+	plainSlices   Slices
+	plainPresence Presence
+)
+
+// corpusType pairs a corpus type with its plain counterpart.
+type corpusType struct {
+	Name string
+	// New returns a pointer to a new value of the corpus type and a pointer
+	// to a new value of the plain type.
+	New func() (generated, plain interface{})
+	// Values returns the values to marshal.
+	Values func() (generated, plain []interface{})
+}
+
+var corpusTypes = []corpusType{
+	{
+		Name: "Scalars",
+		New: func() (interface{}, interface{}) {
+			return &Scalars{}, &plainScalars{}
+		},
+		Values: func() (g, p []interface{}) {
+			for _, v := range []Scalars{
+				{},
+				{
+					Name: "a b&c", Enabled: true, Int: -1, Int8: -128, Int16: 16, Int32: 32, Int64: 64,
+					Uint: 1, Uint8: 255, Uint16: 16, Uint32: 32, Uint64: 1 << 63,
+					Float32: 1.5, Float64: -0.25, Color: "red", Level: 3,
+					IDs: "ids", HTTPServer: "srv", Snake_case: "snake", Explicit: "e",
+					Skipped: "skipped", unexported: "unexported",
+				},
+			} {
+				v := v
+				g = append(g, &v)
+				p = append(p, plainScalars(v))
+			}
+			return
+		},
+	},
+	{
+		Name: "Pointers",
+		New: func() (interface{}, interface{}) {
+			return &Pointers{}, &plainPointers{}
+		},
+		Values: func() (g, p []interface{}) {
+			name, enabled, i, u16, f32, color := "n", true, -5, uint16(7), float32(0.1), Color("blue")
+			opt, nilStr, req := 1, "x", int64(2)
+			for _, v := range []Pointers{
+				{},
+				{
+					Name: new(string), Enabled: new(bool), Int: new(int), Uint16: new(uint16),
+					Float32: new(float32), Color: new(Color), Opt: new(int), Nil: new(string), Req: new(int64),
+				},
+				{
+					Name: &name, Enabled: &enabled, Int: &i, Uint16: &u16,
+					Float32: &f32, Color: &color, Opt: &opt, Nil: &nilStr, Req: &req,
+				},
+			} {
+				v := v
+				g = append(g, &v)
+				p = append(p, plainPointers(v))
+			}
+			return
+		},
+	},
+	{
+		Name: "Slices",
+		New: func() (interface{}, interface{}) {
+			return &Slices{}, &plainSlices{}
+		},
+		Values: func() (g, p []interface{}) {
+			for _, v := range []Slices{
+				{},
+				{Names: []string{}, Ints: []int{}, OmitAll: []int{}},
+				{
+					Names: []string{"a", ""}, Flags: []bool{true, false}, Ints: []int{1, -2},
+					Bytes: []byte("hi"), Floats: []float64{1e21, 0.5}, Colors: []Color{"red"},
+					Levels: []Level{-1, 1}, Opt: []int{1}, Nil: []int{2}, Req: []uint{3}, OmitAll: []int{4},
+				},
+			} {
+				v := v
+				g = append(g, &v)
+				p = append(p, plainSlices(v))
+			}
+			return
+		},
+	},
+	{
+		Name: "Presence",
+		New: func() (interface{}, interface{}) {
+			return &Presence{}, &plainPresence{}
+		},
+		Values: func() (g, p []interface{}) {
+			for _, v := range []Presence{
+				{},
+				{Keep: "k", OmitEmpty: 1, Opt: "o", Nil: 1.5, Req: 2, KeepReq: "kr"},
+			} {
+				v := v
+				g = append(g, &v)
+				p = append(p, plainPresence(v))
+			}
+			return
+		},
+	},
+}
+
+var marshalOptions = []qs.MarshalOptions{
+	{},
+	{DefaultMarshalPresence: qs.OmitEmpty},
+	{DefaultMarshalPresence: qs.OmitDefault},
+	{ArrayFormat: qs.ArrayIndices},
+	{DefaultExplode: qs.NoExplode},
+}
+
+var unmarshalOptions = []qs.UnmarshalOptions{
+	{},
+	{DefaultUnmarshalPresence: qs.Nil},
+	{DefaultUnmarshalPresence: qs.Req},
+	{CollectErrors: true},
+	{ArrayFormat: qs.ArrayComma},
+	{
+		SliceToString: func(a []string) (string, error) {
+			if len(a) == 0 {
+				return "", errors.New("empty")
+			}
+			return a[len(a)-1], nil
+		},
+	},
+}
+
+var queries = []string{
+	"",
+	"name=a+b&enabled=true&int=-1&int8=127&int16=0x10&int32=-0b11&int64=0o17" +
+		"&uint=1&uint8=255&uint16=0x20&uint32=32&uint64=18446744073709551615" +
+		"&float32=1.5&float64=-2e10&color=red&level=-3" +
+		"&i_ds=ids&http_server=srv&snake_case=snake&explicit_NAME=e&skipped=x&unexported=y",
+	"name=a&name=b",
+	"enabled=yes",
+	"int8=128",
+	"uint=-1",
+	"float32=1e40",
+	"level=x",
+	"req=1",
+	"req=x",
+	"req=&keep_req=",
+	"opt=5&nil=6",
+	"opt=&nil=",
+	"names=a&names=b&ints=1&ints=2&bytes=1&flags=false&floats=0.5&colors=c&lvl=1&req=1&omit_all=1",
+	"names=&ints=1&ints=x&req=1",
+	"bytes=256&req=1",
+	"lvl=1,2&req=2",
+	"keep=k&omit_empty=1&opt=o&nil=1.5&req=2&keep_req=kr",
+}
+
+func TestMarshalCorpus(t *testing.T) {
+	for _, ct := range corpusTypes {
+		for i, opts := range marshalOptions {
+			m := qs.NewMarshaler(&opts)
+			gs, ps := ct.Values()
+			for j := range gs {
+				name := fmt.Sprintf("%v/options%v/value%v", ct.Name, i, j)
+				want, wantErr := m.MarshalValues(ps[j])
+				got, err := m.MarshalValues(gs[j])
+				if err != nil || wantErr != nil {
+					t.Errorf("%v: unexpected error: %v, %v", name, err, wantErr)
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%v: got %v, want %v", name, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestUnmarshalCorpus(t *testing.T) {
+	for _, ct := range corpusTypes {
+		for i, opts := range unmarshalOptions {
+			um := qs.NewUnmarshaler(&opts)
+			for _, query := range queries {
+				name := fmt.Sprintf("%v/options%v/%q", ct.Name, i, query)
+				vs, err := url.ParseQuery(query)
+				if err != nil {
+					t.Fatalf("%v: %v", name, err)
+				}
+
+				g, p := ct.New()
+				err = um.UnmarshalValues(g, vs)
+				wantErr := um.UnmarshalValues(p, vs)
+				if msg := compareErrors(err, wantErr); msg != "" {
+					t.Errorf("%v: %v", name, msg)
+					continue
+				}
+				if err != nil {
+					continue
+				}
+				// Converting the plain value to the corpus type for the
+				// comparison.
+				pv := reflect.ValueOf(p).Elem().Convert(reflect.TypeOf(g).Elem())
+				if !reflect.DeepEqual(reflect.ValueOf(g).Elem().Interface(), pv.Interface()) {
+					t.Errorf("%v: got %#v, want %#v", name, g, p)
+				}
+			}
+		}
+	}
+}
+
+// compareErrors returns a non-empty description of the difference between
+// the errors of the generated and the reflection based unmarshaling. The
+// Struct fields of the errors are ignored because they refer to different
+// types.
+func compareErrors(err, want error) string {
+	if err == nil || want == nil {
+		if err != want {
+			return fmt.Sprintf("error == %v, want %v", err, want)
+		}
+		return ""
+	}
+
+	var fes, wantFes []*qs.FieldError
+	var me *qs.MultiError
+	if errors.As(err, &me) {
+		fes = me.FieldErrors()
+	} else if fe, ok := err.(*qs.FieldError); ok {
+		fes = append(fes, fe)
+	}
+	if errors.As(want, &me) {
+		wantFes = me.FieldErrors()
+	} else if fe, ok := want.(*qs.FieldError); ok {
+		wantFes = append(wantFes, fe)
+	}
+	if len(fes) == 0 || len(fes) != len(wantFes) {
+		return fmt.Sprintf("error == %v, want %v", err, want)
+	}
+	for i, fe := range fes {
+		w := wantFes[i]
+		if fe.Field != w.Field || fe.Key != w.Key || !reflect.DeepEqual(fe.Values, w.Values) ||
+			fe.Err.Error() != w.Err.Error() || errors.Is(fe, qs.ErrRequiredField) != errors.Is(w, qs.ErrRequiredField) {
+			return fmt.Sprintf("error == %#v, want %#v", fe, w)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+/*
+Qsgen generates reflection-free MarshalQSValues and UnmarshalQSValues methods
+for struct types. The default ValuesMarshalerFactory and
+ValuesUnmarshalerFactory of the qs package detect these methods and prefer them
+to the reflection based marshaling of the struct.
+
+Usage:
+
+	qsgen -type T1[,T2[...]] [-output file] [directory]
+
+The directory defaults to the current directory and the output file defaults
+to <lowercase name of the first type>_qs.go in that directory. The tool is
+typically used with a go:generate directive:
+
+	//go:generate qsgen -type SearchRequest
+
+The generated code follows the same rules as the reflective path of the qs
+package: the field tags are parsed the same way and the field names without
+explicit names in their tags are converted to snake_case. The supported field
+types are strings, bools, integers and floats (including the named types
+defined in the same package with these underlying types), pointers to them
+and slices of them. The supported tag options are keepempty, omitempty, opt,
+nil and req. qsgen refuses to generate code for structs with other field
+types, tag options or embedded fields.
+
+The generated methods are used only with the options that they support:
+the default name transformer, MarshalerFactory and UnmarshalerFactory, form
+style exploded arrays with the ArrayRepeat format and without the
+CollectErrors option of the unmarshaler. With other options the qs package
+falls back to the reflection based marshaling of the struct.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; required")
+	output := flag.String("output", "", "output file name; default <lowercase first type>_qs.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: qsgen -type T1[,T2[...]] [-output file] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeNames, ",")
+	outputFile := *output
+	if outputFile == "" {
+		outputFile = filepath.Join(dir, strings.ToLower(names[0])+"_qs.go")
+	}
+
+	src, err := generate(dir, names, filepath.Base(outputFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "qsgen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(outputFile, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "qsgen: %v\n", err)
+		os.Exit(1)
+	}
+}
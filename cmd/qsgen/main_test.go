@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateCorpus(t *testing.T) {
+	dir := filepath.Join("internal", "corpus")
+	want, err := ioutil.ReadFile(filepath.Join(dir, "corpus_qs.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(dir, []string{"Scalars", "Pointers", "Slices", "Presence"}, "corpus_qs.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("the generated code differs from internal/corpus/corpus_qs.go - run go generate in that directory")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"missing type", "type S struct{}", "type T not found"},
+		{"not a struct", "type T int", "isn't a struct type"},
+		{"embedded field", "type E struct{}\ntype T struct{ E }", "embedded field E"},
+		{"unsupported field type", "type T struct{ M map[string]int }", "unsupported type: map[string]int"},
+		{"foreign type", "import \"time\"\ntype T struct{ D time.Duration }", "defined in another package"},
+		{"text marshaler", "type C int\nfunc (c C) MarshalText() ([]byte, error) { return nil, nil }\ntype T struct{ C C }", "MarshalText method"},
+		{"unsupported option", "type T struct{ S string `qs:\",default=x\"` }", "option isn't supported by qsgen"},
+		{"surplus comma", "type T struct{ S string `qs:\"s,\"` }", "surplus comma"},
+		{"duplicate presence", "type T struct{ S string `qs:\",opt,req\"` }", "only one UnmarshalPresence"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		src := "package p\n" + test.src + "\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := generate(dir, []string{"T"}, "t_qs.go")
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: error == %v, want an error containing %q", test.name, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Qsvet checks the qs struct tags of the github.com/pasztorpisti/qs package.
+// See the documentation of the qstag analyzer for the list of checks.
+//
+// Usage:
+//
+//	qsvet [package...]
+//
+// or with go vet:
+//
+//	go vet -vettool=$(which qsvet) [package...]
+package main
+
+import (
+	"github.com/pasztorpisti/qs/analysis/qstag"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(qstag.Analyzer)
+}
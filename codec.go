@@ -0,0 +1,82 @@
+package qs
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+)
+
+// Codec marshals and unmarshals values of type T. The ValuesMarshaler and
+// ValuesUnmarshaler of T are created only once by NewCodec so the Codec
+// doesn't have to look them up in the caches of the factories on every call.
+// A Codec is safe for concurrent use.
+type Codec[T any] struct {
+	m   *QSMarshaler
+	um  *QSUnmarshaler
+	vm  ValuesMarshaler
+	vum ValuesUnmarshaler
+}
+
+// NewCodec creates a Codec for the struct or map type T. A nil mopts or uopts
+// is the same as passing a pointer to zero options. NewCodec returns an error
+// if T can't be marshaled or unmarshaled (see CheckMarshalType and
+// CheckUnmarshalType).
+func NewCodec[T any](mopts *MarshalOptions, uopts *UnmarshalOptions) (*Codec[T], error) {
+	if mopts == nil {
+		mopts = &MarshalOptions{}
+	}
+	if uopts == nil {
+		uopts = &UnmarshalOptions{}
+	}
+	c := &Codec[T]{
+		m:  NewMarshaler(mopts),
+		um: NewUnmarshaler(uopts),
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	var err error
+	c.vm, err = c.m.opts.ValuesMarshalerFactory.ValuesMarshaler(t, c.m.opts)
+	if err != nil {
+		return nil, err
+	}
+	c.vum, err = c.um.opts.ValuesUnmarshalerFactory.ValuesUnmarshaler(t, c.um.opts)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Marshal marshals the value pointed to by v into a query string.
+func (c *Codec[T]) Marshal(v *T) (string, error) {
+	values, err := c.MarshalValues(v)
+	if err != nil {
+		return "", err
+	}
+	return c.m.encode(values), nil
+}
+
+// MarshalValues marshals the value pointed to by v into a url.Values.
+func (c *Codec[T]) MarshalValues(v *T) (url.Values, error) {
+	if v == nil {
+		return nil, errors.New("nil pointer")
+	}
+	return c.vm.MarshalValues(reflect.ValueOf(v).Elem(), c.m.opts)
+}
+
+// Unmarshal unmarshals a query string into the value pointed to by into.
+func (c *Codec[T]) Unmarshal(into *T, queryString string) error {
+	values, err := c.um.parseQuery(queryString)
+	if err != nil {
+		return err
+	}
+	return c.UnmarshalValues(into, values)
+}
+
+// UnmarshalValues unmarshals a url.Values into the value pointed to by into.
+func (c *Codec[T]) UnmarshalValues(into *T, values url.Values) error {
+	if into == nil {
+		return errors.New("nil pointer")
+	}
+	_, err := c.um.decode(c.vum, reflect.ValueOf(into).Elem(), values, false)
+	return err
+}
@@ -0,0 +1,72 @@
+package qs
+
+import "testing"
+
+type codecQuery struct {
+	Query string   `qs:"q"`
+	Page  int      `qs:",default=1"`
+	Tags  []string `qs:",omitempty"`
+}
+
+func TestCodec(t *testing.T) {
+	c, err := NewCodec[codecQuery](nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.Marshal(&codecQuery{Query: "a b", Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "page=2&q=a+b" {
+		t.Errorf("query string == %q, want %q", s, "page=2&q=a+b")
+	}
+
+	var q codecQuery
+	if err := c.Unmarshal(&q, "q=x&tags=a&tags=b"); err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Query", q.Query, "x")
+	cr.compare("Page", q.Page, 1)
+	cr.compare("len(Tags)", len(q.Tags), 2)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	if err := c.Unmarshal(nil, "q=x"); err == nil {
+		t.Error("unexpected success with nil pointer")
+	}
+}
+
+func TestCodecOptions(t *testing.T) {
+	c, err := NewCodec[codecQuery](
+		&MarshalOptions{SpaceEscape: SpaceAsPercent20},
+		&UnmarshalOptions{DisallowUnknownKeys: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.Marshal(&codecQuery{Query: "a b", Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "page=2&q=a%20b" {
+		t.Errorf("query string == %q, want %q", s, "page=2&q=a%20b")
+	}
+
+	var q codecQuery
+	if err := c.Unmarshal(&q, "x=1"); err == nil {
+		t.Error("unexpected success with unknown key")
+	}
+}
+
+func TestNewCodecUnsupportedType(t *testing.T) {
+	if _, err := NewCodec[int](nil, nil); err == nil {
+		t.Error("unexpected success")
+	}
+	if _, err := NewCodec[struct{ C chan int }](nil, nil); err == nil {
+		t.Error("unexpected success")
+	}
+}
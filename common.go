@@ -3,11 +3,14 @@ package qs
 import (
 	"errors"
 	"fmt"
+	"net/textproto"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
-	"unicode"
+
+	"github.com/pasztorpisti/qs/internal/tagparse"
 )
 
 const tagKey = "qs"
@@ -20,15 +23,39 @@ type NameTransformFunc func(string) string
 
 var stringType = reflect.TypeOf("")
 var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
 var urlType = reflect.TypeOf(url.URL{})
 
 type parsedTag struct {
 	Name              string
 	MarshalPresence   MarshalPresence
 	UnmarshalPresence UnmarshalPresence
+	Style             Style
+	Explode           ExplodeMode
+	// Default is the raw value used by the unmarshaler when the key of the
+	// field is missing. It is valid only if HasDefault is true.
+	Default    string
+	HasDefault bool
+	// Constraints are checked after unmarshaling the value of the field.
+	Constraints []constraint
+	// Remain marks the field that holds the keys that aren't consumed by
+	// the other fields of the struct.
+	Remain bool
+	// TimeLayout overrides the TimeLayout of the marshal and unmarshal
+	// options for the field. It is empty if the field doesn't have a layout,
+	// unix or unixmilli option.
+	TimeLayout string
+	// Sources holds the request sources of the field used by BindRequest.
+	// It is zero if the tag doesn't have an in option.
+	Sources requestSource
 }
 
-func getStructFieldInfo(field reflect.StructField, nt NameTransformFunc, defaultMarshalPresence MarshalPresence,
+// getStructFieldInfo parses the tag of a struct field. key is the struct tag
+// key: tagKey or headerTagKey. In case of headerTagKey the returned tag.Name
+// is the canonical header name of the field and nt isn't used: the field
+// names are converted to dash separated words (e.g.: RequestID becomes
+// Request-Id).
+func getStructFieldInfo(field reflect.StructField, key string, nt NameTransformFunc, defaultMarshalPresence MarshalPresence,
 	defaultUnmarshalPresence UnmarshalPresence) (skip bool, tag parsedTag, err error) {
 	// Skipping unexported fields.
 	if field.PkgPath != "" && !field.Anonymous {
@@ -36,9 +63,9 @@ func getStructFieldInfo(field reflect.StructField, nt NameTransformFunc, default
 		return
 	}
 
-	tag, err = parseFieldTag(field.Tag, defaultMarshalPresence, defaultUnmarshalPresence)
+	tag, err = parseTagValue(field.Tag.Get(key), defaultMarshalPresence, defaultUnmarshalPresence)
 	if err != nil {
-		err = fmt.Errorf("invalid tag: %q :: %v", field.Tag, err)
+		err = fmt.Errorf("invalid tag: %q :: %w", field.Tag, err)
 		return
 	}
 
@@ -48,6 +75,18 @@ func getStructFieldInfo(field reflect.StructField, nt NameTransformFunc, default
 		return
 	}
 
+	if key == headerTagKey {
+		if tag.Remain {
+			err = errors.New("the remain option can't be used in header tags")
+			return
+		}
+		if tag.Name == "" {
+			tag.Name = strings.Replace(snakeCase(field.Name), "_", "-", -1)
+		}
+		tag.Name = textproto.CanonicalMIMEHeaderKey(tag.Name)
+		return
+	}
+
 	if tag.Name == "" {
 		tag.Name = nt(field.Name)
 	}
@@ -55,76 +94,392 @@ func getStructFieldInfo(field reflect.StructField, nt NameTransformFunc, default
 	return
 }
 
-func parseFieldTag(tagStr reflect.StructTag, defaultMarshalPresence MarshalPresence,
-	defaultUnmarshalPresence UnmarshalPresence) (tag parsedTag, err error) {
-	v := tagStr.Get(tagKey)
-	arr := strings.Split(v, ",")
-	tag.Name = arr[0]
-
-	setMarshalPresence := func(v MarshalPresence) {
-		if tag.MarshalPresence != MPUnspecified {
-			err = fmt.Errorf("only one MarshalPresence option is allwed - you've specified at least two: %v, %v", tag.MarshalPresence, v)
-		}
-		tag.MarshalPresence = v
-	}
-
-	setUnmarshalPresence := func(v UnmarshalPresence) {
-		if tag.UnmarshalPresence != UPUnspecified {
-			err = fmt.Errorf("only one UnmarshalPresence option is allwed - you've specified at least two: %v, %v", tag.UnmarshalPresence, v)
-		}
-		tag.UnmarshalPresence = v
-	}
-
-	for _, option := range arr[1:] {
-		switch option {
-		case "nil":
-			setUnmarshalPresence(Nil)
-		case "opt":
-			setUnmarshalPresence(Opt)
-		case "req":
-			setUnmarshalPresence(Req)
-		case "keepempty":
-			setMarshalPresence(KeepEmpty)
-		case "omitempty":
-			setMarshalPresence(OmitEmpty)
-		case "":
-			err = errors.New("tag string contains a surplus comma")
+var stringSliceType = reflect.TypeOf([]string(nil))
+
+// checkRemainField returns an error if a field with the remain tag option
+// can't hold the remaining keys of the query string. Only url.Values and
+// map[string][]string fields are accepted.
+func checkRemainField(sf reflect.StructField, tag parsedTag) error {
+	t := sf.Type
+	if t.Kind() != reflect.Map || t.Key() != stringType || t.Elem() != stringSliceType {
+		return fmt.Errorf("the remain option requires a url.Values or map[string][]string field, got %v", t)
+	}
+	if tag.UnmarshalPresence == Req {
+		return errors.New("the remain option can't be combined with the req option")
+	}
+	return nil
+}
+
+// isNestedType returns true if values of type t can be marshaled as nested
+// values whose keys are prefixed with the name of the struct field that holds
+// them. This is used only as a fallback when the MarshalerFactory or
+// UnmarshalerFactory can't handle the type of the field.
+//
+// Structs, maps and arrays/slices of structs, maps or arrays/slices are nested
+// types.
+func isNestedType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return true
+	case reflect.Array, reflect.Slice:
+		et := t.Elem()
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		switch et.Kind() {
+		case reflect.Struct, reflect.Map, reflect.Array, reflect.Slice:
+			return true
+		}
+	}
+	return false
+}
+
+// isRecursiveType returns true if the values of type t can contain values of
+// a type that contains itself through pointers, arrays, slices, maps and the
+// fields (including the embedded fields) of structs. The marshaler factories
+// would recurse infinitely on such types so they reject them as soon as they
+// reach the first field of a recursive type no matter how long the chain of
+// types leading back to it is. key is the struct tag key of the fields.
+func isRecursiveType(t reflect.Type, key string) bool {
+	return hasTypeCycle(t, key, map[reflect.Type]bool{}, map[reflect.Type]bool{})
+}
+
+// isRecursiveElemType returns true if the element types of the pointer,
+// array, slice or map type t lead back to t (e.g.: type S []S). The
+// marshaler factories of these kinds reject such types because they would
+// recurse infinitely on their element types.
+func isRecursiveElemType(t reflect.Type) bool {
+	seen := map[reflect.Type]bool{}
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
 		default:
-			err = fmt.Errorf("invalid option in field tag: %q", option)
+			return false
 		}
-		if err != nil {
-			return
+		if seen[t] {
+			return true
 		}
+		seen[t] = true
+		t = t.Elem()
 	}
+}
 
-	if tag.MarshalPresence == MPUnspecified {
-		tag.MarshalPresence = defaultMarshalPresence
+// hasTypeCycle walks the types reachable from t depth-first. path holds the
+// types on the path from the root to t and done the types already known to
+// be free of cycles. Unexported and skipped ("-") struct fields are ignored
+// because the marshaler factories don't walk them either.
+func hasTypeCycle(t reflect.Type, key string, path, done map[reflect.Type]bool) bool {
+	if path[t] {
+		return true
 	}
-	if tag.UnmarshalPresence == UPUnspecified {
-		tag.UnmarshalPresence = defaultUnmarshalPresence
+	if done[t] {
+		return false
+	}
+	path[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+		if hasTypeCycle(t.Elem(), key, path, done) {
+			return true
+		}
+	case reflect.Struct:
+		for i, numField := 0, t.NumField(); i < numField; i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous {
+				continue
+			}
+			if strings.SplitN(sf.Tag.Get(key), ",", 2)[0] == "-" {
+				continue
+			}
+			if hasTypeCycle(sf.Type, key, path, done) {
+				return true
+			}
+		}
 	}
+	delete(path, t)
+	done[t] = true
+	return false
+}
 
-	return
+// KeySyntax controls how the keys of nested values (e.g.: the fields of nested
+// structs) are joined to the key of the struct field that holds them.
+type KeySyntax int
+
+// defaultKeySyntax is used by the NewMarshaler and NewUnmarshaler functions
+// when the KeySyntax field of their options is KSUnspecified.
+const defaultKeySyntax = DotSyntax
+
+const (
+	// KSUnspecified is the zero value of KeySyntax. In most cases you will use
+	// this implicitly by simply leaving the KeySyntax field of MarshalOptions
+	// or UnmarshalOptions uninitialised which results in using the default
+	// KeySyntax which is DotSyntax.
+	KSUnspecified KeySyntax = iota
+
+	// DotSyntax joins the segments of nested keys with dots.
+	// E.g.: "user.address.city".
+	DotSyntax
+
+	// BracketSyntax puts the segments of nested keys after the first one into
+	// square brackets like PHP and Rails. E.g.: "user[address][city]".
+	BracketSyntax
+)
+
+func (v KeySyntax) String() string {
+	switch v {
+	case KSUnspecified:
+		return "KSUnspecified"
+	case DotSyntax:
+		return "DotSyntax"
+	case BracketSyntax:
+		return "BracketSyntax"
+	default:
+		return fmt.Sprintf("KeySyntax(%v)", int(v))
+	}
 }
 
-// snakeCase converts CamelCase names to snake_case with lowercase letters and
-// underscores. Names already in snake_case are left untouched.
-func snakeCase(s string) string {
-	in := []rune(s)
-	isLower := func(idx int) bool {
-		return idx >= 0 && idx < len(in) && unicode.IsLower(in[idx])
+// join joins the key of a struct field and a key of the nested value stored
+// in that field. E.g.: "filter" and "status" are joined into "filter.status"
+// with DotSyntax and "filter[status]" with BracketSyntax.
+//
+// Array and slice indices are always put into square brackets so the keys of
+// the items of arrays and slices start with the index in square brackets.
+// E.g.: "items" and "[0].name" are joined into "items[0].name".
+func (v KeySyntax) join(parent, child string) string {
+	if strings.HasPrefix(child, "[") {
+		return parent + child
+	}
+	if v != BracketSyntax {
+		return parent + "." + child
+	}
+	i := strings.IndexByte(child, '[')
+	if i < 0 {
+		return parent + "[" + child + "]"
+	}
+	return parent + "[" + child[:i] + "]" + child[i:]
+}
+
+// cut splits a key into its first segment and the rest of the key. The rest
+// is returned in the same format as the keys of the nested values before
+// joining except that a leading array index isn't put into square brackets.
+// E.g.: "user[address][city]" is split into "user" and "address[city]" with
+// BracketSyntax and "items[0].name" is split into "items" and "0.name" with
+// DotSyntax.
+// The ok return value is false if the key consists of only one segment.
+func (v KeySyntax) cut(key string) (head, rest string, ok bool) {
+	separators := ".["
+	if v == BracketSyntax {
+		separators = "["
+	}
+	i := strings.IndexAny(key, separators)
+	if i < 0 {
+		return key, "", false
+	}
+	if key[i] == '.' {
+		return key[:i], key[i+1:], true
 	}
 
-	out := make([]rune, 0, len(in)+len(in)/2)
-	for i, r := range in {
-		if unicode.IsUpper(r) {
-			r = unicode.ToLower(r)
-			if i > 0 && in[i-1] != '_' && (isLower(i-1) || isLower(i+1)) {
-				out = append(out, '_')
+	j := closingBracket(key, i)
+	if j < 0 {
+		return key, "", false
+	}
+	return key[:i], key[i+1:j] + key[j+1:], true
+}
+
+// closingBracket returns the index of the "]" that closes the "[" at index i
+// of key or -1 if there is no such "]". Brackets are nested only in the
+// literal segments created by limitDepth (e.g.: "a[[b][c]]") so the first
+// "]" closes the bracket unless it is followed by another "[".
+func closingBracket(key string, i int) int {
+	if i+1 >= len(key) || key[i+1] != '[' {
+		j := strings.IndexByte(key[i:], ']')
+		if j < 0 {
+			return -1
+		}
+		return i + j
+	}
+	depth := 0
+	for j := i; j < len(key); j++ {
+		switch key[j] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j
 			}
 		}
-		out = append(out, r)
 	}
+	return -1
+}
+
+// limitDepth limits the number of nested segments in the keys of vs to
+// maxDepth. Like the parse function of the JavaScript qs library, it keeps
+// the segments after the limit as a single literal segment in square
+// brackets. E.g.: "a[b][c][d]" becomes "a[b][[c][d]]" with a maxDepth of 1
+// so its value is unmarshaled as the "[c][d]" key of the nested value "b".
+func limitDepth(vs url.Values, ks KeySyntax, maxDepth int) url.Values {
+	var limited url.Values
+	for k, a := range vs {
+		depth := 0
+		for _, rest, ok := ks.cut(k); ok; _, rest, ok = ks.cut(rest) {
+			depth++
+		}
+		if depth > maxDepth {
+			k = limitKeyDepth(k, ks, maxDepth)
+		}
+		if limited == nil {
+			limited = make(url.Values, len(vs))
+		}
+		limited[k] = append(limited[k], a...)
+	}
+	return limited
+}
+
+// limitKeyDepth keeps the first maxDepth nested segments of key and puts the
+// rest of the key into square brackets. The dot separated segments of the
+// rest are converted into bracketed segments with DotSyntax (e.g.: "c.d" into
+// "[c][d]").
+func limitKeyDepth(key string, ks KeySyntax, maxDepth int) string {
+	head, rest, _ := ks.cut(key)
+	limited := head
+	for depth := 0; depth < maxDepth; depth++ {
+		head, rest, _ = ks.cut(rest)
+		limited += "[" + head + "]"
+	}
+
+	separators := ".["
+	if ks == BracketSyntax {
+		separators = "["
+	}
+	i := strings.IndexAny(rest, separators)
+	if i < 0 {
+		return limited + "[[" + rest + "]]"
+	}
+	tail := rest[i:]
+	if ks != BracketSyntax {
+		tail = bracketDots(tail)
+	}
+	return limited + "[[" + rest[:i] + "]" + tail + "]"
+}
 
-	return string(out)
+// bracketDots converts the dot separated segments of a key into bracketed
+// segments the same way as the allowDots option of qs.parse: "." followed by
+// characters other than "." and "[" is converted into the characters in
+// square brackets.
+func bracketDots(key string) string {
+	if !strings.Contains(key, ".") {
+		return key
+	}
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		j := i + 1
+		for key[i] == '.' && j < len(key) && key[j] != '.' && key[j] != '[' {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(key[i])
+			continue
+		}
+		b.WriteString("[" + key[i+1:j] + "]")
+		i = j - 1
+	}
+	return b.String()
+}
+
+// parseIndex parses an array or slice index found in a key of the query
+// string.
+func parseIndex(s string, maxIndex int) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil || s[0] == '+' || s[0] == '-' {
+		return 0, fmt.Errorf("invalid array/slice index: %q", s)
+	}
+	if i > maxIndex {
+		return 0, fmt.Errorf("array/slice index %v is greater than the maximum (%v)", i, maxIndex)
+	}
+	return i, nil
+}
+
+// subValues returns the entries of vs that belong to the nested value of the
+// struct field with the given name. The keys of the returned url.Values don't
+// contain the name of the field as a prefix.
+func subValues(vs url.Values, name string, ks KeySyntax) url.Values {
+	var sub url.Values
+	for k, a := range vs {
+		head, rest, ok := ks.cut(k)
+		if !ok || head != name {
+			continue
+		}
+		if sub == nil {
+			sub = make(url.Values)
+		}
+		sub[rest] = a
+	}
+	return sub
+}
+
+func parseFieldTag(tagStr reflect.StructTag, defaultMarshalPresence MarshalPresence,
+	defaultUnmarshalPresence UnmarshalPresence) (tag parsedTag, err error) {
+	return parseTagValue(tagStr.Get(tagKey), defaultMarshalPresence, defaultUnmarshalPresence)
+}
+
+// parseTagValue parses the value of a qs or header struct tag.
+func parseTagValue(v string, defaultMarshalPresence MarshalPresence,
+	defaultUnmarshalPresence UnmarshalPresence) (tag parsedTag, err error) {
+	t, err := tagparse.Parse(v)
+	if err != nil {
+		return
+	}
+
+	tag = parsedTag{
+		Name:        t.Name,
+		Default:     t.Default,
+		HasDefault:  t.HasDefault,
+		Constraints: t.Constraints,
+		Remain:      t.Remain,
+		TimeLayout:  t.TimeLayout,
+		Sources:     requestSources(t.In),
+	}
+
+	switch t.MarshalPresence {
+	case "keepempty":
+		tag.MarshalPresence = KeepEmpty
+	case "omitempty":
+		tag.MarshalPresence = OmitEmpty
+	case "omitdefault":
+		tag.MarshalPresence = OmitDefault
+	default:
+		tag.MarshalPresence = defaultMarshalPresence
+	}
+	switch t.UnmarshalPresence {
+	case "nil":
+		tag.UnmarshalPresence = Nil
+	case "opt":
+		tag.UnmarshalPresence = Opt
+	case "req":
+		tag.UnmarshalPresence = Req
+	default:
+		tag.UnmarshalPresence = defaultUnmarshalPresence
+	}
+
+	if t.Style != "" {
+		if tag.Style, err = parseStyle(t.Style); err != nil {
+			return
+		}
+	}
+	switch t.Explode {
+	case "true":
+		tag.Explode = Explode
+	case "false":
+		tag.Explode = NoExplode
+	}
+	return
+}
+
+// snakeCase converts CamelCase names to snake_case with lowercase letters and
+// underscores. Names already in snake_case are left untouched.
+func snakeCase(s string) string {
+	return tagparse.SnakeCase(s)
 }
@@ -96,7 +96,7 @@ func TestParseTag_IncompatibleOptions(t *testing.T) {
 			t.Errorf("unexpected success - tag: %q", tagStr)
 			continue
 		}
-		if !strings.Contains(err.Error(), "option is allwed - you've specified at least two") {
+		if !strings.Contains(err.Error(), "option is allowed - you've specified at least two") {
 			t.Errorf("expected a different error :: %v", err)
 		}
 	}
@@ -145,3 +145,62 @@ func TestSnakeCase(t *testing.T) {
 		}
 	}
 }
+
+func TestKeySyntax(t *testing.T) {
+	testCases := []struct {
+		ks     KeySyntax
+		parent string
+		child  string
+		key    string
+	}{
+		{DotSyntax, "a", "b", "a.b"},
+		{DotSyntax, "a", "b.c", "a.b.c"},
+		{BracketSyntax, "a", "b", "a[b]"},
+		{BracketSyntax, "a", "b[c]", "a[b][c]"},
+		{BracketSyntax, "a", "b[c][d]", "a[b][c][d]"},
+	}
+	for _, tc := range testCases {
+		key := tc.ks.join(tc.parent, tc.child)
+		if key != tc.key {
+			t.Errorf("%v.join(%q, %q) == %q, want %q", tc.ks, tc.parent, tc.child, key, tc.key)
+		}
+		head, rest, ok := tc.ks.cut(key)
+		if !ok || head != tc.parent || rest != tc.child {
+			t.Errorf("%v.cut(%q) == (%q, %q, %v), want (%q, %q, true)",
+				tc.ks, key, head, rest, ok, tc.parent, tc.child)
+		}
+	}
+
+	for _, key := range []string{"a", "a]", "a[b"} {
+		if _, _, ok := BracketSyntax.cut(key); ok {
+			t.Errorf("BracketSyntax.cut(%q) succeeded unexpectedly", key)
+		}
+	}
+}
+
+func TestKeySyntaxIndices(t *testing.T) {
+	testCases := []struct {
+		ks     KeySyntax
+		parent string
+		child  string
+		key    string
+		rest   string
+	}{
+		{DotSyntax, "a", "[0]", "a[0]", "0"},
+		{DotSyntax, "a", "[0].b", "a[0].b", "0.b"},
+		{DotSyntax, "a", "[0][1].b", "a[0][1].b", "0[1].b"},
+		{BracketSyntax, "a", "[0]", "a[0]", "0"},
+		{BracketSyntax, "a", "[0][b]", "a[0][b]", "0[b]"},
+	}
+	for _, tc := range testCases {
+		key := tc.ks.join(tc.parent, tc.child)
+		if key != tc.key {
+			t.Errorf("%v.join(%q, %q) == %q, want %q", tc.ks, tc.parent, tc.child, key, tc.key)
+		}
+		head, rest, ok := tc.ks.cut(key)
+		if !ok || head != tc.parent || rest != tc.rest {
+			t.Errorf("%v.cut(%q) == (%q, %q, %v), want (%q, %q, true)",
+				tc.ks, key, head, rest, ok, tc.parent, tc.rest)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package qs
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/pasztorpisti/qs/internal/tagparse"
+)
+
+// constraint is a validation rule parsed from a "key=value" option of a
+// field tag. See tagparse.Constraint.
+type constraint = tagparse.Constraint
+
+// checkConstraints returns an error if any of the constraints in the tag of a
+// struct field can't be used with the type of the field.
+func checkConstraints(t reflect.Type, tag parsedTag) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	et := t
+	if k := t.Kind(); k == reflect.Array || k == reflect.Slice {
+		et = t.Elem()
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+	}
+
+	for _, c := range tag.Constraints {
+		switch c.Name {
+		case "min", "max":
+			if !isNumericKind(et.Kind()) {
+				return fmt.Errorf("the %v option requires a numeric field: %v", c.Name, t)
+			}
+		case "len", "maxlen":
+			if k := t.Kind(); k != reflect.String && k != reflect.Array && k != reflect.Slice {
+				return fmt.Errorf("the %v option requires a string, array or slice field: %v", c.Name, t)
+			}
+		case "pattern":
+			if et.Kind() != reflect.String {
+				return fmt.Errorf("the %v option requires a string field: %v", c.Name, t)
+			}
+		}
+	}
+	return nil
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkConstraintValues returns a *ConstraintError if the unmarshaled value
+// of a field violates any of the constraints.
+func checkConstraintValues(cs []constraint, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	for _, c := range cs {
+		switch k := v.Kind(); {
+		case c.Name == "len" || c.Name == "maxlen":
+			n := v.Len()
+			if k == reflect.String {
+				n = utf8.RuneCountInString(v.String())
+			}
+			if (c.Name == "len" && n != c.Length) || (c.Name == "maxlen" && n > c.Length) {
+				return &ConstraintError{Constraint: c.Name, Param: c.Param}
+			}
+		case k == reflect.Array || k == reflect.Slice:
+			for i, n := 0, v.Len(); i < n; i++ {
+				if err := checkConstraintItem(&c, v.Index(i)); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := checkConstraintItem(&c, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkConstraintItem checks a value constraint against a single value.
+func checkConstraintItem(c *constraint, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	ok := true
+	switch c.Name {
+	case "min", "max":
+		var f float64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(v.Uint())
+		default:
+			f = v.Float()
+		}
+		ok = (c.Name == "min" && f >= c.Num) || (c.Name == "max" && f <= c.Num)
+	case "pattern":
+		ok = c.Pattern.MatchString(v.String())
+	case "oneof":
+		s := formatValue(v)
+		ok = false
+		for _, item := range c.OneOf {
+			if s == item {
+				ok = true
+				break
+			}
+		}
+	}
+	if !ok {
+		return &ConstraintError{Constraint: c.Name, Param: c.Param}
+	}
+	return nil
+}
+
+// formatValue formats a value for comparison with the items of the oneof
+// constraint.
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	default:
+		if v.CanInterface() {
+			return fmt.Sprint(v.Interface())
+		}
+		return v.String()
+	}
+}
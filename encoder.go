@@ -0,0 +1,223 @@
+package qs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+)
+
+// valuesSink receives the key-value pairs produced by the builtin
+// ValuesMarshalers.
+type valuesSink interface {
+	// add adds the values of a key. Adding the same key more than once
+	// overwrites the previous values in a url.Values but writes the key
+	// again in a query string.
+	add(key string, a []string)
+
+	// has returns true if the key has been added. It is used by the remain
+	// fields that don't overwrite the keys of the other fields.
+	has(key string) bool
+}
+
+// keyTracker is implemented by the builtin ValuesMarshalers that can marshal
+// remain fields. The sinks that don't store the keys (e.g.: queryWriter) have
+// to track all keys from the start for the has method of such marshalers.
+type keyTracker interface {
+	// tracksKeys returns true if the marshaled value contains a field with
+	// the remain tag option (including embedded and nested structs).
+	tracksKeys() bool
+}
+
+// tracksKeys returns true if the keys added to the sink by vm have to be
+// tracked. ValuesMarshalers that don't implement keyTracker (e.g.: custom
+// ValuesMarshalers) don't call the has method of the sink.
+func tracksKeys(vm ValuesMarshaler) bool {
+	if kt, ok := vm.(keyTracker); ok {
+		return kt.tracksKeys()
+	}
+	return false
+}
+
+// sinkMarshaler is implemented by the builtin ValuesMarshalers that can
+// write their output directly to a valuesSink without building a url.Values.
+type sinkMarshaler interface {
+	marshalTo(s valuesSink, v reflect.Value, opts *MarshalOptions) error
+}
+
+// marshalTo marshals v into s. ValuesMarshalers that don't implement
+// sinkMarshaler (e.g.: custom ValuesMarshalers) are marshaled into a
+// url.Values whose keys are added to s in sorted order.
+func marshalTo(vm ValuesMarshaler, s valuesSink, v reflect.Value, opts *MarshalOptions) error {
+	if sm, ok := vm.(sinkMarshaler); ok {
+		return sm.marshalTo(s, v, opts)
+	}
+	vs, err := vm.MarshalValues(v, opts)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(vs))
+	for k := range vs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s.add(k, vs[k])
+	}
+	return nil
+}
+
+// valuesMapSink implements valuesSink by storing the values in a url.Values.
+type valuesMapSink url.Values
+
+func (s valuesMapSink) add(key string, a []string) {
+	s[key] = a
+}
+
+func (s valuesMapSink) has(key string) bool {
+	_, ok := s[key]
+	return ok
+}
+
+// prefixSink implements valuesSink by joining the keys of a nested value to
+// the key of the field that holds the nested value.
+type prefixSink struct {
+	Sink      valuesSink
+	Prefix    string
+	KeySyntax KeySyntax
+}
+
+func (s *prefixSink) add(key string, a []string) {
+	s.Sink.add(s.KeySyntax.join(s.Prefix, key), a)
+}
+
+func (s *prefixSink) has(key string) bool {
+	return s.Sink.has(s.KeySyntax.join(s.Prefix, key))
+}
+
+// queryWriter implements valuesSink by appending the escaped key-value pairs
+// to a query string.
+type queryWriter struct {
+	Buf              []byte
+	SpaceAsPercent20 bool
+	// Start is the length of Buf before writing the first key.
+	Start int
+	// Keys holds the added keys if it isn't nil. It is created only if the
+	// marshaled value has a remain field (see keyTracker).
+	Keys map[string]bool
+}
+
+func (w *queryWriter) add(key string, a []string) {
+	for _, s := range a {
+		if len(w.Buf) > w.Start {
+			w.Buf = append(w.Buf, '&')
+		}
+		w.Buf = w.appendEscaped(w.Buf, key)
+		w.Buf = append(w.Buf, '=')
+		w.Buf = w.appendEscaped(w.Buf, s)
+	}
+	if w.Keys != nil {
+		w.Keys[key] = true
+	}
+}
+
+func (w *queryWriter) has(key string) bool {
+	return w.Keys[key]
+}
+
+// appendEscaped appends s to dst escaped the same way as url.QueryEscape
+// escapes it.
+func (w *queryWriter) appendEscaped(dst []byte, s string) []byte {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			dst = append(dst, c)
+		case c == ' ' && !w.SpaceAsPercent20:
+			dst = append(dst, '+')
+		default:
+			dst = append(dst, '%', hex[c>>4], hex[c&15])
+		}
+	}
+	return dst
+}
+
+// AppendMarshal is the same as Marshal but it appends the query string to dst
+// and returns the extended buffer. It writes the keys directly from the
+// marshaled object without building a url.Values so the keys of maps are
+// written in sorted order while the keys of structs are written in the
+// following order: the keys of the fields declared by the struct in the order
+// of declaration, then the keys of its embedded structs and finally the keys
+// of its remain field in sorted order. (Marshal sorts all keys.)
+//
+// The keys of custom ValuesMarshalers are written in sorted order after
+// calling their MarshalValues method.
+func AppendMarshal(dst []byte, i interface{}) ([]byte, error) {
+	return DefaultMarshaler.AppendMarshal(dst, i)
+}
+
+// AppendMarshal appends the query string of the given object to dst.
+// See the documentation of the global AppendMarshal func.
+func (p *QSMarshaler) AppendMarshal(dst []byte, i interface{}) ([]byte, error) {
+	v := reflect.ValueOf(i)
+	if !v.IsValid() {
+		return dst, errors.New("received an empty interface")
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return dst, fmt.Errorf("nil pointer of type %T", i)
+		}
+		v = v.Elem()
+	}
+
+	vm, err := p.opts.ValuesMarshalerFactory.ValuesMarshaler(v.Type(), p.opts)
+	if err != nil {
+		return dst, err
+	}
+	w := &queryWriter{
+		Buf:              dst,
+		SpaceAsPercent20: p.opts.SpaceEscape == SpaceAsPercent20,
+		Start:            len(dst),
+	}
+	if tracksKeys(vm) {
+		w.Keys = make(map[string]bool)
+	}
+	if err := marshalTo(vm, w, v, p.opts); err != nil {
+		return dst, err
+	}
+	return w.Buf, nil
+}
+
+// Encoder writes query strings to an io.Writer. It reuses its internal buffer
+// between the Encode calls.
+type Encoder struct {
+	w   io.Writer
+	m   *QSMarshaler
+	buf []byte
+}
+
+// NewEncoder returns an Encoder that uses DefaultMarshaler to write to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return DefaultMarshaler.NewEncoder(w)
+}
+
+// NewEncoder returns an Encoder that uses the marshaler to write to w.
+func (p *QSMarshaler) NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, m: p}
+}
+
+// Encode writes the query string of the given object to the io.Writer of the
+// Encoder. See AppendMarshal for the order of the keys.
+func (e *Encoder) Encode(i interface{}) error {
+	buf, err := e.m.AppendMarshal(e.buf[:0], i)
+	if err != nil {
+		return err
+	}
+	e.buf = buf
+	_, err = e.w.Write(buf)
+	return err
+}
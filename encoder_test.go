@@ -0,0 +1,197 @@
+package qs
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type encoderFilter struct {
+	Status string
+	Tags   []string `qs:",omitempty"`
+}
+
+type encoderQuery struct {
+	Query   string `qs:"q"`
+	Page    int
+	Since   time.Time
+	IDs     []int `qs:"ids"`
+	Filter  encoderFilter
+	Items   []encoderFilter
+	Labels  map[string]string
+	Empty   *int       `qs:",omitempty"`
+	Extra   url.Values `qs:",remain"`
+	Special string
+}
+
+var encoderTestQuery = encoderQuery{
+	Query:   "a b&c",
+	Page:    2,
+	Since:   time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC),
+	IDs:     []int{1, 2},
+	Filter:  encoderFilter{Status: "open", Tags: []string{"x"}},
+	Items:   []encoderFilter{{Status: "a"}, {Status: "b"}},
+	Labels:  map[string]string{"z": "1", "a": "2"},
+	Extra:   url.Values{"utm": {"x"}, "page": {"99"}},
+	Special: "~-_.!*'()+/?=ü",
+}
+
+func TestAppendMarshal(t *testing.T) {
+	for _, opts := range []*MarshalOptions{
+		{},
+		{KeySyntax: BracketSyntax, ArrayFormat: ArrayIndices, SpaceEscape: SpaceAsPercent20},
+	} {
+		m := NewMarshaler(opts)
+		expected, err := m.MarshalValues(&encoderTestQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := m.AppendMarshal([]byte("prefix?"), &encoderTestQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(b, []byte("prefix?")) {
+			t.Fatalf("the prefix of the buffer has been overwritten: %q", b)
+		}
+		s := string(b[len("prefix?"):])
+		vs, err := url.ParseQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := expectValues(vs, expected); err != nil {
+			t.Errorf("opts=%+v: %v", opts, err)
+		}
+
+		// Marshal sorts the keys so only the escaping can be compared.
+		qs, err := m.Marshal(&encoderTestQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(qs) != len(s) {
+			t.Errorf("opts=%+v: AppendMarshal == %q, Marshal == %q", opts, s, qs)
+		}
+	}
+}
+
+func TestAppendMarshalOrder(t *testing.T) {
+	type s struct {
+		B      string
+		A      string
+		Labels map[string]int
+	}
+	b, err := AppendMarshal(nil, s{B: "1", A: "2", Labels: map[string]int{"y": 1, "x": 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "b=1&a=2&labels.x=2&labels.y=1"
+	if string(b) != want {
+		t.Errorf("AppendMarshal == %q, want %q", b, want)
+	}
+
+	type embedded struct {
+		D string
+		C string
+	}
+	type withEmbedded struct {
+		Extra url.Values `qs:",remain"`
+		embedded
+		B string
+		A string
+	}
+	b, err = AppendMarshal(nil, withEmbedded{
+		Extra:    url.Values{"z": {"1"}, "e": {"2"}},
+		embedded: embedded{D: "3", C: "4"},
+		B:        "5",
+		A:        "6",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "b=5&a=6&d=3&c=4&e=2&z=1"
+	if string(b) != want {
+		t.Errorf("AppendMarshal == %q, want %q", b, want)
+	}
+
+	if _, err := AppendMarshal(nil, (*s)(nil)); err == nil {
+		t.Error("unexpected success with nil pointer")
+	}
+}
+
+type encoderExtra struct {
+	Rest url.Values `qs:",remain"`
+}
+
+func TestAppendMarshalEmbeddedRemain(t *testing.T) {
+	type query struct {
+		encoderExtra
+		Page int
+	}
+	type nested struct {
+		Q []query
+	}
+	v := query{
+		encoderExtra: encoderExtra{Rest: url.Values{"page": {"1"}, "x": {"2"}}},
+		Page:         5,
+	}
+
+	qs, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := AppendMarshal(nil, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "page=5&x=2"
+	if qs != want || string(b) != want {
+		t.Errorf("Marshal == %q, AppendMarshal == %q, want %q", qs, b, want)
+	}
+
+	b, err = AppendMarshal(nil, &nested{Q: []query{v}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "q%5B0%5D.page=5&q%5B0%5D.x=2"
+	if string(b) != want {
+		t.Errorf("AppendMarshal == %q, want %q", b, want)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	if err := e.Encode(&encoderFilter{Status: "open"}); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteByte('\n')
+	if err := e.Encode(&encoderFilter{Status: "closed", Tags: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "status=open\nstatus=closed&tags=a"
+	if buf.String() != want {
+		t.Errorf("output == %q, want %q", buf.String(), want)
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(&encoderTestQuery); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendMarshal(b *testing.B) {
+	b.ReportAllocs()
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		var err error
+		buf, err = AppendMarshal(buf[:0], &encoderTestQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
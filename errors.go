@@ -1,54 +1,260 @@
 package qs
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
+// ErrRequiredField is the cause of the FieldError returned by the unmarshaler
+// when a struct field marked with the 'req' option isn't in the unmarshaled
+// url.Values or query string.
+var ErrRequiredField = errors.New("missing required field")
+
+// ConstraintError is the cause of the FieldError returned by the unmarshaler
+// when the value of a struct field violates one of the constraint options
+// (min, max, len, maxlen, pattern or oneof) in its tag.
+type ConstraintError struct {
+	// Constraint is the name of the violated tag option. E.g.: "min".
+	Constraint string
+	// Param is the value of the violated tag option. E.g.: "1".
+	Param string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("value violates constraint %v=%v", e.Constraint, e.Param)
+}
+
+// MapKeyError is the cause of the FieldError returned by the unmarshaler
+// when a key of the query string can't be converted into the key type of a
+// map.
+type MapKeyError struct {
+	// Key is the offending key.
+	Key string
+	// Type is the key type of the map.
+	Type reflect.Type
+	// Err is the cause of the error.
+	Err error
+}
+
+func (e *MapKeyError) Error() string {
+	return fmt.Sprintf("invalid map key %q for type %v :: %v", e.Key, e.Type, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *MapKeyError) Unwrap() error {
+	return e.Err
+}
+
 // IsRequiredFieldError returns ok==false if the given error wasn't caused by a
 // required field that was missing from the query string.
-// Otherwise it returns the name of the missing required field with ok==true.
+// Otherwise it returns the key of the missing required field with ok==true.
+// The key of a field of a nested struct contains the keys of the outer
+// fields (e.g.: "filter.status"). In case of a *MultiError the key of the
+// first missing required field is returned.
 func IsRequiredFieldError(e error) (fieldName string, ok bool) {
-	if re, ok := e.(*reqError); ok {
-		return re.FieldName, true
+	var me *MultiError
+	if errors.As(e, &me) {
+		if keys := me.MissingFields(); len(keys) != 0 {
+			return keys[0], true
+		}
+		return "", false
+	}
+	if !errors.Is(e, ErrRequiredField) {
+		return "", false
+	}
+	var fe *FieldError
+	if errors.As(e, &fe) {
+		return fe.Key, true
+	}
+	return "", true
+}
+
+// FieldError is returned by the unmarshaler when it fails to unmarshal the
+// value of a struct field. The errors of nested values (fields of nested and
+// embedded structs, items of arrays, slices and maps) are merged into a single
+// FieldError that describes the full path of the value.
+type FieldError struct {
+	// Struct is the type of the outermost struct that contains the field.
+	// It is nil if the outermost value isn't a struct (e.g.: a map).
+	Struct reflect.Type
+
+	// Field is the path of the field relative to Struct in Go syntax.
+	// E.g.: "Filter.Status", "Items[1].Name" or `Filters["status"]`.
+	Field string
+
+	// Key is the key of the field in the query string.
+	// E.g.: "filter.status", "items[1].name" or "filters[status]".
+	Key string
+
+	// Values holds the raw values of the key. It is nil if the field is
+	// missing from the query string or if the error isn't related to the
+	// values of a single key.
+	Values []string
+
+	// Err is the cause of the error.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	if e.Struct == nil {
+		return fmt.Sprintf("error unmarshaling key %q :: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("error unmarshaling field %v of struct %v (key %q) :: %v",
+		e.Field, e.Struct, e.Key, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// newFieldError wraps an error that occurred while unmarshaling the value of
+// field with the given key. If err is a *FieldError of a nested value then
+// its path is prefixed with the field and key parameters. An empty key means
+// that the value of the field is unmarshaled from the keys of the outer value
+// (e.g.: embedded structs).
+func newFieldError(st reflect.Type, field, key string, values []string, ks KeySyntax, err error) *FieldError {
+	fe, ok := err.(*FieldError)
+	if !ok {
+		return &FieldError{
+			Struct: st,
+			Field:  field,
+			Key:    key,
+			Values: values,
+			Err:    err,
+		}
+	}
+
+	if key != "" {
+		key = ks.join(key, fe.Key)
+	} else {
+		key = fe.Key
+	}
+	if strings.HasPrefix(fe.Field, "[") {
+		field += fe.Field
+	} else {
+		field += "." + fe.Field
+	}
+	return &FieldError{
+		Struct: st,
+		Field:  field,
+		Key:    key,
+		Values: fe.Values,
+		Err:    fe.Err,
 	}
-	return "", false
 }
 
-// reqError is returned when a struct field marked with the 'req' option isn't
-// in the unmarshaled url.Values or query string.
-type reqError struct {
-	Message   string
-	FieldName string
+// MultiError is returned by the unmarshaler instead of the first error when
+// the CollectErrors option is set. It holds the errors of all struct fields
+// that failed to unmarshal.
+type MultiError struct {
+	// Errors holds the *FieldError values of the failed fields in the
+	// order they were found followed by an *UnknownKeysError if the
+	// DisallowUnknownKeys option is set and there were unknown keys.
+	Errors []error
 }
 
-func (e *reqError) Error() string {
-	return e.Message
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%v unmarshal errors: %v", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the collected errors so errors.Is and errors.As check all
+// of them.
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// FieldErrors returns the collected errors that are *FieldError values.
+func (e *MultiError) FieldErrors() []*FieldError {
+	var fes []*FieldError
+	for _, err := range e.Errors {
+		if fe, ok := err.(*FieldError); ok {
+			fes = append(fes, fe)
+		}
+	}
+	return fes
+}
+
+// MissingFields returns the keys of the required fields that were missing
+// from the query string.
+func (e *MultiError) MissingFields() []string {
+	var keys []string
+	for _, fe := range e.FieldErrors() {
+		if errors.Is(fe.Err, ErrRequiredField) {
+			keys = append(keys, fe.Key)
+		}
+	}
+	return keys
+}
+
+// fieldErrors collects the errors of the fields of a struct or the items of
+// a container. Nested errors are added with their path prefixed with the
+// field that holds the nested value (see newFieldError).
+type fieldErrors struct {
+	Struct  reflect.Type
+	Collect bool
+	Errors  []error
+}
+
+// add records the error of a field. It returns true if the unmarshaling has
+// to stop because errors aren't collected.
+func (p *fieldErrors) add(field, key string, values []string, ks KeySyntax, err error) bool {
+	if me, ok := err.(*MultiError); ok {
+		for _, e := range me.Errors {
+			p.Errors = append(p.Errors, newFieldError(p.Struct, field, key, values, ks, e))
+		}
+	} else {
+		p.Errors = append(p.Errors, newFieldError(p.Struct, field, key, values, ks, err))
+	}
+	return !p.Collect
+}
+
+// err returns the recorded error or a *MultiError if errors are collected.
+func (p *fieldErrors) err() error {
+	if len(p.Errors) == 0 {
+		return nil
+	}
+	if !p.Collect {
+		return p.Errors[0]
+	}
+	return &MultiError{Errors: p.Errors}
 }
 
-type wrongTypeError struct {
+// WrongTypeError is returned by the marshalers and unmarshalers when they
+// receive a value of a type other than the one they were created for.
+type WrongTypeError struct {
 	Actual   reflect.Type
 	Expected reflect.Type
 }
 
-func (e *wrongTypeError) Error() string {
+func (e *WrongTypeError) Error() string {
 	return fmt.Sprintf("received type %v, want %v", e.Actual, e.Expected)
 }
 
-type wrongKindError struct {
+// WrongKindError is returned by the marshaler and unmarshaler factories when
+// they are asked to handle a type of an unexpected kind.
+type WrongKindError struct {
 	Actual   reflect.Type
 	Expected reflect.Kind
 }
 
-func (e *wrongKindError) Error() string {
+func (e *WrongKindError) Error() string {
 	return fmt.Sprintf("received type %v of kind %v, want kind %v",
 		e.Actual, e.Actual.Kind(), e.Expected)
 }
 
-type unhandledTypeError struct {
+// UnhandledTypeError is returned by the marshaler and unmarshaler factories
+// when they don't support the requested type.
+type UnhandledTypeError struct {
 	Type reflect.Type
 }
 
-func (e *unhandledTypeError) Error() string {
+func (e *UnhandledTypeError) Error() string {
 	return fmt.Sprintf("unhandled type: %v", e.Type)
 }
@@ -0,0 +1,173 @@
+package qs
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+// fieldKey is the query string key of a struct field.
+type fieldKey struct {
+	// Key is the name of the field in the query string.
+	Key string
+	// Field is the Go path of the field relative to the struct.
+	// E.g.: "Embedded.Name".
+	Field string
+	// Depth is the embedding depth of the field. It is zero for the fields
+	// of the struct itself.
+	Depth int
+}
+
+// fieldKeyLister is implemented by the builtin ValuesMarshalers and
+// ValuesUnmarshalers of structs and pointers to structs. It is used to resolve
+// the conflicting keys of the fields of a struct and its embedded structs.
+type fieldKeyLister interface {
+	// fieldKeys returns the keys of the visible fields of the struct
+	// including the visible fields of its embedded structs.
+	fieldKeys() []fieldKey
+}
+
+// embeddedFieldKeys returns the keys of the fields of the embedded struct
+// field with the given name relative to the embedding struct. x is the
+// ValuesMarshaler or ValuesUnmarshaler of the embedded field. It returns nil
+// if x doesn't implement fieldKeyLister (e.g.: maps and custom
+// ValuesMarshalers) because their keys are known only at runtime.
+func embeddedFieldKeys(name string, x interface{}) []fieldKey {
+	l, ok := x.(fieldKeyLister)
+	if !ok {
+		return nil
+	}
+	keys := l.fieldKeys()
+	res := make([]fieldKey, len(keys))
+	for i, fk := range keys {
+		res[i] = fieldKey{Key: fk.Key, Field: name + "." + fk.Field, Depth: fk.Depth + 1}
+	}
+	return res
+}
+
+// resolveFieldKeys resolves the conflicting keys of the fields of struct t
+// using Go's embedding rules: the fields of the outer struct shadow the
+// fields of the embedded structs and more than one field with the same key
+// at the same depth is an error.
+//
+// The own parameter holds the keys of the fields of t, the embedded parameter
+// holds the keys of each embedded field of t (see embeddedFieldKeys). It
+// returns the keys of the visible fields and the shadowed keys of each
+// embedded field (nil if an embedded field has no shadowed keys).
+func resolveFieldKeys(t reflect.Type, own []fieldKey, embedded [][]fieldKey) (
+	visible []fieldKey, shadowed []map[string]bool, err error) {
+	all := append([]fieldKey(nil), own...)
+	// owners holds the index of the embedded field of each item of all.
+	owners := make([]int, len(own))
+	for i := range owners {
+		owners[i] = -1
+	}
+	for i, keys := range embedded {
+		all = append(all, keys...)
+		for range keys {
+			owners = append(owners, i)
+		}
+	}
+
+	minDepth := make(map[string]int, len(all))
+	for _, fk := range all {
+		if d, ok := minDepth[fk.Key]; !ok || fk.Depth < d {
+			minDepth[fk.Key] = fk.Depth
+		}
+	}
+
+	shadowed = make([]map[string]bool, len(embedded))
+	first := make(map[string]fieldKey, len(all))
+	for i, fk := range all {
+		if fk.Depth > minDepth[fk.Key] {
+			o := owners[i]
+			if shadowed[o] == nil {
+				shadowed[o] = map[string]bool{}
+			}
+			shadowed[o][fk.Key] = true
+			continue
+		}
+		if f, ok := first[fk.Key]; ok {
+			return nil, nil, fmt.Errorf("fields %v and %v of struct %v have the same key: %q",
+				f.Field, fk.Field, t, fk.Key)
+		}
+		first[fk.Key] = fk
+		visible = append(visible, fk)
+	}
+	return visible, shadowed, nil
+}
+
+// isShadowedKey returns true if key belongs to one of the shadowed field keys.
+// The keys of nested values start with the key of the field that holds them
+// followed by a separator of the given key syntax (e.g.: "filter.status",
+// "ids[0]").
+func isShadowedKey(key string, shadowed map[string]bool, ks KeySyntax) bool {
+	if shadowed[key] {
+		return true
+	}
+	head, _, _ := ks.cut(key)
+	return shadowed[head]
+}
+
+// shadowSink implements valuesSink by dropping the shadowed keys of an
+// embedded struct.
+type shadowSink struct {
+	Sink      valuesSink
+	Shadowed  map[string]bool
+	KeySyntax KeySyntax
+}
+
+func (s *shadowSink) add(key string, a []string) {
+	if !isShadowedKey(key, s.Shadowed, s.KeySyntax) {
+		s.Sink.add(key, a)
+	}
+}
+
+func (s *shadowSink) has(key string) bool {
+	return s.Sink.has(key)
+}
+
+// withoutShadowedKeys returns the entries of vs that don't belong to the
+// shadowed keys of an embedded struct.
+func withoutShadowedKeys(vs url.Values, shadowed map[string]bool, ks KeySyntax) url.Values {
+	if shadowed == nil {
+		return vs
+	}
+	res := make(url.Values, len(vs))
+	for k, a := range vs {
+		if !isShadowedKey(k, shadowed, ks) {
+			res[k] = a
+		}
+	}
+	return res
+}
+
+func (p *structMarshaler) fieldKeys() []fieldKey {
+	return p.Keys
+}
+
+func (p *structUnmarshaler) fieldKeys() []fieldKey {
+	return p.Keys
+}
+
+func (p *ptrValuesMarshaler) fieldKeys() []fieldKey {
+	if l, ok := p.ElemMarshaler.(fieldKeyLister); ok {
+		return l.fieldKeys()
+	}
+	return nil
+}
+
+func (p *ptrValuesUnmarshaler) fieldKeys() []fieldKey {
+	if l, ok := p.ElemUnmarshaler.(fieldKeyLister); ok {
+		return l.fieldKeys()
+	}
+	return nil
+}
+
+func (p *generatedMarshaler) fieldKeys() []fieldKey {
+	return p.Fallback.Keys
+}
+
+func (p *generatedUnmarshaler) fieldKeys() []fieldKey {
+	return p.Fallback.Keys
+}
@@ -0,0 +1,161 @@
+package qs
+
+import (
+	"net/url"
+	"reflect"
+)
+
+// MarshalQSValues is implemented by the struct types whose marshaling code has
+// been generated by the qsgen tool (github.com/pasztorpisti/qs/cmd/qsgen).
+// The default ValuesMarshalerFactory prefers the MarshalQSValues method to the
+// reflection based marshaling of the struct when the options of the marshaler
+// are supported by the generated code (see qsgen).
+type MarshalQSValues interface {
+	MarshalQSValues(opts *MarshalOptions) (url.Values, error)
+}
+
+// UnmarshalQSValues is implemented by the struct types whose unmarshaling code
+// has been generated by the qsgen tool. The default ValuesUnmarshalerFactory
+// prefers the UnmarshalQSValues method to the reflection based unmarshaling
+// of the struct when the options of the unmarshaler are supported by the
+// generated code (see qsgen).
+type UnmarshalQSValues interface {
+	UnmarshalQSValues(vs url.Values, opts *UnmarshalOptions) error
+}
+
+var (
+	marshalQSValuesInterfaceType   = reflect.TypeOf((*MarshalQSValues)(nil)).Elem()
+	unmarshalQSValuesInterfaceType = reflect.TypeOf((*UnmarshalQSValues)(nil)).Elem()
+)
+
+// hasGeneratedMethod returns true if the pointer to the struct type t has
+// the method of the given interface and the method isn't promoted from an
+// embedded field. A method of an embedded struct would marshal only the
+// fields of the embedded struct.
+func hasGeneratedMethod(t, iface reflect.Type) bool {
+	if t.Kind() != reflect.Struct || !reflect.PtrTo(t).Implements(iface) {
+		return false
+	}
+	for i, numField := 0, t.NumField(); i < numField; i++ {
+		sf := t.Field(i)
+		if !sf.Anonymous {
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() != reflect.Ptr {
+			ft = reflect.PtrTo(ft)
+		}
+		if ft.Implements(iface) {
+			// We can't tell whether t has its own method that shadows
+			// the promoted one so we play it safe.
+			return false
+		}
+	}
+	return true
+}
+
+// isDefaultNameTransformer returns true if nt is the snakeCase function that
+// is used by the generated code to name the fields without explicit names.
+func isDefaultNameTransformer(nt NameTransformFunc) bool {
+	return reflect.ValueOf(nt).Pointer() == reflect.ValueOf(snakeCase).Pointer()
+}
+
+// supportsGeneratedMarshaler returns true if the generated code marshals the
+// fields the same way as the given options. The generated code hardwires the
+// builtin marshalers of the primitive types and the default name transformer.
+func supportsGeneratedMarshaler(opts *MarshalOptions) bool {
+	c, ok := opts.MarshalerFactory.(*marshalerCache)
+	return ok && c.wrapped == defaultMarshalerFactory && isDefaultNameTransformer(opts.NameTransformer)
+}
+
+// supportsGeneratedUnmarshaler returns true if the generated code unmarshals
+// the fields the same way as the given options.
+func supportsGeneratedUnmarshaler(opts *UnmarshalOptions) bool {
+	c, ok := opts.UnmarshalerFactory.(*unmarshalerCache)
+	return ok && c.wrapped == defaultUnmarshalerFactory && isDefaultNameTransformer(opts.NameTransformer)
+}
+
+// generatedMarshaler implements ValuesMarshaler by calling the generated
+// MarshalQSValues method of a struct. It falls back to the reflection based
+// structMarshaler when the options aren't supported by the generated code.
+type generatedMarshaler struct {
+	Fallback *structMarshaler
+}
+
+func newGeneratedMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshaler, error) {
+	vm, err := newStructMarshaler(t, opts)
+	if err != nil || !supportsGeneratedMarshaler(opts) {
+		return vm, err
+	}
+	return &generatedMarshaler{Fallback: vm.(*structMarshaler)}, nil
+}
+
+func (p *generatedMarshaler) MarshalValues(v reflect.Value, opts *MarshalOptions) (url.Values, error) {
+	t := v.Type()
+	if t != p.Fallback.Type {
+		return nil, &WrongTypeError{Actual: t, Expected: p.Fallback.Type}
+	}
+	// The methods of the values of unexported embedded fields can't be
+	// called.
+	if opts.ArrayFormat != ArrayRepeat || opts.DefaultStyle != FormStyle || opts.DefaultExplode == NoExplode ||
+		!v.CanInterface() {
+		return p.Fallback.MarshalValues(v, opts)
+	}
+
+	if p.Fallback.BeforeMarshal {
+		var err error
+		if v, err = callBeforeMarshal(v, opts); err != nil {
+			return nil, err
+		}
+	}
+	if !v.CanAddr() {
+		c := reflect.New(t).Elem()
+		c.Set(v)
+		v = c
+	}
+	return v.Addr().Interface().(MarshalQSValues).MarshalQSValues(opts)
+}
+
+// generatedUnmarshaler implements ValuesUnmarshaler by calling the generated
+// UnmarshalQSValues method of a struct. It falls back to the reflection based
+// structUnmarshaler when the options aren't supported by the generated code.
+type generatedUnmarshaler struct {
+	Fallback *structUnmarshaler
+}
+
+func newGeneratedUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (ValuesUnmarshaler, error) {
+	vum, err := newStructUnmarshaler(t, opts)
+	if err != nil || !supportsGeneratedUnmarshaler(opts) {
+		return vum, err
+	}
+	return &generatedUnmarshaler{Fallback: vum.(*structUnmarshaler)}, nil
+}
+
+func (p *generatedUnmarshaler) UnmarshalValues(v reflect.Value, vs url.Values, opts *UnmarshalOptions) error {
+	t := v.Type()
+	if t != p.Fallback.Type {
+		return &WrongTypeError{Actual: t, Expected: p.Fallback.Type}
+	}
+	if opts.ArrayFormat != ArrayRepeat || opts.DefaultStyle != FormStyle || opts.DefaultExplode == NoExplode ||
+		opts.CollectErrors || !v.CanAddr() || !v.CanInterface() {
+		return p.Fallback.UnmarshalValues(v, vs, opts)
+	}
+
+	err := v.Addr().Interface().(UnmarshalQSValues).UnmarshalQSValues(vs, opts)
+	if err != nil {
+		// The generated code doesn't know the reflect.Type of the struct.
+		if fe, ok := err.(*FieldError); ok && fe.Struct == nil {
+			fe.Struct = t
+		}
+		return err
+	}
+	return callAfterUnmarshal(v, opts, p.Fallback.AfterUnmarshal, p.Fallback.Validate)
+}
+
+func (p *generatedUnmarshaler) claimsKey(key string, opts *UnmarshalOptions) bool {
+	return p.Fallback.claimsKey(key, opts)
+}
+
+func (p *generatedUnmarshaler) collectPresence(fs FieldSet, field, key string, vs url.Values, opts *UnmarshalOptions) {
+	p.Fallback.collectPresence(fs, field, key, vs, opts)
+}
@@ -0,0 +1,143 @@
+package qs
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// genQuery mimics the methods generated by qsgen but its MarshalQSValues and
+// UnmarshalQSValues methods mark their output to tell them apart from the
+// reflection based path.
+type genQuery struct {
+	Name   string
+	Before bool `qs:"-"`
+	After  bool `qs:"-"`
+}
+
+func (p *genQuery) MarshalQSValues(opts *MarshalOptions) (url.Values, error) {
+	return url.Values{"name": {"generated:" + p.Name}}, nil
+}
+
+func (p *genQuery) UnmarshalQSValues(vs url.Values, opts *UnmarshalOptions) error {
+	if a, ok := vs["name"]; ok {
+		if a[0] == "fail" {
+			return &FieldError{Field: "Name", Key: "name", Values: a, Err: errors.New("fail")}
+		}
+		p.Name = "generated:" + a[0]
+	}
+	return nil
+}
+
+func (p *genQuery) BeforeMarshalQS(opts *MarshalOptions) error {
+	p.Before = true
+	p.Name = strings.ToUpper(p.Name)
+	return nil
+}
+
+func (p *genQuery) AfterUnmarshalQS(opts *UnmarshalOptions) error {
+	p.After = true
+	return nil
+}
+
+// genValues has the generated methods without hooks.
+type genValues struct {
+	Name string
+}
+
+func (p *genValues) MarshalQSValues(opts *MarshalOptions) (url.Values, error) {
+	return url.Values{"name": {"generated:" + p.Name}}, nil
+}
+
+func (p *genValues) UnmarshalQSValues(vs url.Values, opts *UnmarshalOptions) error {
+	p.Name = "generated:" + vs.Get("name")
+	return nil
+}
+
+// genValuesEmbedder has promoted methods from genValues that must be
+// ignored. The methods of the unexported embedded field can't be called so
+// it is handled by the reflection based path.
+type genValuesEmbedder struct {
+	genValues
+	Page int
+}
+
+func TestMarshalGenerated(t *testing.T) {
+	vs, err := MarshalValues(genQuery{Name: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{"name": {"generated:X"}}); err != nil {
+		t.Error(err)
+	}
+
+	// Options that aren't supported by the generated code.
+	for _, opts := range []*MarshalOptions{
+		{ArrayFormat: ArrayComma},
+		{DefaultExplode: NoExplode},
+		{NameTransformer: strings.ToUpper},
+	} {
+		vs, err := NewMarshaler(opts).MarshalValues(&genQuery{Name: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := opts.NameTransformer
+		if key == nil {
+			key = snakeCase
+		}
+		if err := expectValues(vs, url.Values{key("Name"): {"X"}}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	vs, err = MarshalValues(&genValuesEmbedder{genValues: genValues{Name: "x"}, Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{"name": {"x"}, "page": {"2"}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalGenerated(t *testing.T) {
+	var q genQuery
+	if err := Unmarshal(&q, "name=x"); err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Name", q.Name, "generated:x")
+	cr.compare("After", q.After, true)
+
+	q = genQuery{}
+	if err := NewUnmarshaler(&UnmarshalOptions{CollectErrors: true}).Unmarshal(&q, "name=x"); err != nil {
+		t.Fatal(err)
+	}
+	cr.compare("CollectErrors Name", q.Name, "x")
+
+	var e genValuesEmbedder
+	if err := Unmarshal(&e, "name=x&page=2"); err != nil {
+		t.Fatal(err)
+	}
+	cr.compare("embedder Name", e.Name, "x")
+	cr.compare("embedder Page", e.Page, 2)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	q = genQuery{}
+	err := Unmarshal(&q, "name=fail")
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fe.Struct != reflect.TypeOf(q) || fe.Key != "name" || q.After {
+		t.Errorf("unexpected error: %#v", fe)
+	}
+
+	err = NewUnmarshaler(&UnmarshalOptions{DisallowUnknownKeys: true}).Unmarshal(&q, "name=x&page=1")
+	if _, ok := err.(*UnknownKeysError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package qs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// headerTagKey is the struct tag key used by MarshalHeader and
+// UnmarshalHeader instead of the qs tag key.
+const headerTagKey = "header"
+
+// MarshalHeader marshals a struct into an http.Header. It works similarly to
+// MarshalValues with the following differences:
+//
+// The header names are taken from the header struct tag
+// (e.g.: `header:"X-Request-Id"`) instead of the qs tag. The header tag
+// accepts the same options as the qs tag except remain. Fields without a name
+// in their header tag are marshaled under their CamelCase names converted to
+// dash separated words (e.g.: RequestID becomes Request-Id). The header names
+// are converted to their canonical form with textproto.CanonicalMIMEHeaderKey.
+//
+// Headers are flat: the field types have to be supported by the
+// MarshalerFactory of the marshaler (nested structs and maps aren't supported)
+// but the fields of embedded structs are marshaled as the fields of the
+// embedding struct.
+//
+// The items of slices and arrays are joined into a single comma separated
+// header value as defined by RFC 7230. Items that contain commas or double
+// quotes are marshaled as quoted strings.
+func MarshalHeader(i interface{}) (http.Header, error) {
+	return DefaultMarshaler.MarshalHeader(i)
+}
+
+// UnmarshalHeader unmarshals an http.Header into a struct. The struct fields
+// are mapped to headers the same way as in case of MarshalHeader. The header
+// names are matched case-insensitively: the keys of h are converted to their
+// canonical form with textproto.CanonicalMIMEHeaderKey.
+//
+// The comma separated list values of slice and array fields are split into
+// items (quoted strings are unquoted and empty items are ignored) as defined
+// by RFC 7230. A header that appears several times is combined into a single
+// comma separated value in case of other fields.
+//
+// Absent headers leave their fields alone unless they have a default value or
+// the req option. Unlike in case of Unmarshal the nil pointers and slices of
+// fields with the opt option aren't initialised.
+//
+// The UnmarshalOptions.DisallowUnknownKeys option is ignored because the
+// headers of a request usually have many entries unrelated to the struct.
+func UnmarshalHeader(into interface{}, h http.Header) error {
+	return DefaultUnmarshaler.UnmarshalHeader(into, h)
+}
+
+// MarshalHeader marshals a given struct into an http.Header.
+// See the documentation of the global MarshalHeader func.
+func (p *QSMarshaler) MarshalHeader(i interface{}) (http.Header, error) {
+	v := reflect.ValueOf(i)
+	if !v.IsValid() {
+		return nil, errors.New("received an empty interface")
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("nil pointer of type %T", i)
+		}
+		v = v.Elem()
+	}
+
+	sm, err := p.headerMarshaler(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	h := make(http.Header, len(sm.Fields))
+	if err := sm.marshalTo(valuesMapSink(h), v, p.opts); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// headerMarshaler returns the cached header marshaler of struct type t.
+func (p *QSMarshaler) headerMarshaler(t reflect.Type) (*structMarshaler, error) {
+	if item, ok := p.headerCache.Load(t); ok {
+		if sm, ok := item.(*structMarshaler); ok {
+			return sm, nil
+		}
+		return nil, item.(error)
+	}
+
+	sm, err := newTaggedStructMarshaler(t, headerTagKey, p.opts)
+	if err != nil {
+		p.headerCache.Store(t, err)
+	} else {
+		p.headerCache.Store(t, sm)
+	}
+	return sm, err
+}
+
+// UnmarshalHeader unmarshals a given http.Header into a struct.
+// See the documentation of the global UnmarshalHeader func.
+func (p *QSUnmarshaler) UnmarshalHeader(into interface{}, h http.Header) error {
+	pv := reflect.ValueOf(into)
+	if !pv.IsValid() {
+		return errors.New("received an empty interface")
+	}
+	if pv.Kind() != reflect.Ptr {
+		return fmt.Errorf("expected a pointer, got %T", into)
+	}
+	if pv.IsNil() {
+		return fmt.Errorf("nil pointer of type %T", into)
+	}
+	v := pv.Elem()
+
+	su, err := p.headerUnmarshaler(v.Type())
+	if err != nil {
+		return err
+	}
+	return su.UnmarshalValues(v, url.Values(canonicalHeader(h)), p.opts)
+}
+
+// headerUnmarshaler returns the cached header unmarshaler of struct type t.
+func (p *QSUnmarshaler) headerUnmarshaler(t reflect.Type) (*structUnmarshaler, error) {
+	if item, ok := p.headerCache.Load(t); ok {
+		if su, ok := item.(*structUnmarshaler); ok {
+			return su, nil
+		}
+		return nil, item.(error)
+	}
+
+	su, err := newTaggedStructUnmarshaler(t, headerTagKey, p.opts)
+	if err != nil {
+		p.headerCache.Store(t, err)
+	} else {
+		p.headerCache.Store(t, su)
+	}
+	return su, err
+}
+
+// embeddedHeaderStruct returns the struct type of an embedded field whose
+// fields are treated as the fields of the embedding struct. It returns a nil
+// type for embedded fields with an explicit header name and for embedded
+// non-struct types. Unexported embedded fields are skipped unless their fields
+// can be promoted: embedded pointers to unexported structs are skipped because
+// the unmarshaler can't allocate them.
+func embeddedHeaderStruct(sf reflect.StructField) (t reflect.Type, skip bool) {
+	name := strings.SplitN(sf.Tag.Get(headerTagKey), ",", 2)[0]
+	t = sf.Type
+	isPtr := t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+	if name != "" || t.Kind() != reflect.Struct {
+		return nil, sf.PkgPath != ""
+	}
+	if isPtr && sf.PkgPath != "" {
+		return nil, true
+	}
+	return t, false
+}
+
+// newEmbeddedHeaderMarshaler creates the marshaler of an embedded struct (or
+// pointer to struct) field whose fields are marshaled as the fields of the
+// embedding struct. It returns a nil marshaler for the embedded fields that
+// are marshaled as regular fields. See embeddedHeaderStruct.
+func newEmbeddedHeaderMarshaler(sf reflect.StructField, opts *MarshalOptions) (vm ValuesMarshaler, skip bool, err error) {
+	et, skip := embeddedHeaderStruct(sf)
+	if et == nil || skip {
+		return
+	}
+	sm, err := newTaggedStructMarshaler(et, headerTagKey, opts)
+	if err != nil {
+		return
+	}
+	if sf.Type.Kind() == reflect.Ptr {
+		return &ptrValuesMarshaler{Type: sf.Type, ElemMarshaler: sm}, false, nil
+	}
+	return sm, false, nil
+}
+
+// newEmbeddedHeaderUnmarshaler is the unmarshaler counterpart of
+// newEmbeddedHeaderMarshaler. Nil embedded pointers are allocated.
+func newEmbeddedHeaderUnmarshaler(sf reflect.StructField, opts *UnmarshalOptions) (vum ValuesUnmarshaler, skip bool, err error) {
+	et, skip := embeddedHeaderStruct(sf)
+	if et == nil || skip {
+		return
+	}
+	su, err := newTaggedStructUnmarshaler(et, headerTagKey, opts)
+	if err != nil {
+		return
+	}
+	if sf.Type.Kind() == reflect.Ptr {
+		return &ptrValuesUnmarshaler{Type: sf.Type, ElemType: et, ElemUnmarshaler: su}, false, nil
+	}
+	return su, false, nil
+}
+
+// headerFieldValues returns the values of a header to be unmarshaled by um.
+// The comma separated lists of multi-value unmarshalers (e.g.: slices) are
+// split into items while the repeated headers of the other fields are
+// combined into a single comma separated value.
+func headerFieldValues(a []string, um Unmarshaler) []string {
+	switch {
+	case a == nil:
+		return nil
+	case isMultiValueUnmarshaler(um):
+		return splitHeaderList(a)
+	case len(a) > 1:
+		return []string{strings.Join(a, ", ")}
+	default:
+		return a
+	}
+}
+
+// canonicalHeader returns h with canonical keys. It returns h itself if all
+// of its keys are canonical (e.g.: the headers of the requests received by
+// net/http). The values of keys that differ only in case are merged in the
+// order of their sorted original keys.
+func canonicalHeader(h http.Header) http.Header {
+	canonical := true
+	for k := range h {
+		if textproto.CanonicalMIMEHeaderKey(k) != k {
+			canonical = false
+			break
+		}
+	}
+	if canonical {
+		return h
+	}
+
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make(http.Header, len(h))
+	for _, k := range keys {
+		ck := textproto.CanonicalMIMEHeaderKey(k)
+		res[ck] = append(res[ck], h[k]...)
+	}
+	return res
+}
+
+// joinHeaderList joins the items of a slice or array into a comma separated
+// list header value (RFC 7230 section 7). The items that would be changed by
+// splitHeaderList are marshaled as quoted strings.
+func joinHeaderList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		if item == "" || strings.ContainsAny(item, ",\"") || strings.Trim(item, " \t") != item {
+			item = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(item) + `"`
+		}
+		quoted[i] = item
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// splitHeaderList splits the comma separated list values of a header into
+// items (RFC 7230 section 7). Empty items are ignored, the optional
+// whitespace around the items is trimmed and quoted strings are unquoted.
+func splitHeaderList(a []string) []string {
+	items := []string{}
+	for _, s := range a {
+		var item []byte
+		inQuotes, quotedItem := false, false
+		flush := func() {
+			if quotedItem {
+				items = append(items, string(item))
+			} else if trimmed := strings.Trim(string(item), " \t"); trimmed != "" {
+				items = append(items, trimmed)
+			}
+			item, quotedItem = nil, false
+		}
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			switch {
+			case inQuotes && c == '\\' && i+1 < len(s):
+				i++
+				item = append(item, s[i])
+			case inQuotes && c == '"':
+				inQuotes = false
+			case inQuotes:
+				item = append(item, c)
+			case c == '"' && strings.Trim(string(item), " \t") == "":
+				inQuotes, quotedItem = true, true
+				item = item[:0]
+			case c == ',':
+				flush()
+			case quotedItem && (c == ' ' || c == '\t'):
+				// Skipping the whitespace after the closing quote.
+			default:
+				item = append(item, c)
+			}
+		}
+		flush()
+	}
+	return items
+}
@@ -0,0 +1,306 @@
+package qs
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type HTracing struct {
+	TraceID string `header:"X-Trace-Id"`
+	SpanID  string `header:"x-span-id,omitempty"`
+}
+
+type HRequest struct {
+	RequestID string `header:"X-Request-Id"`
+	UserAgent string
+	Accept    []string
+	Retries   *int          `header:",nil"`
+	Timeout   time.Duration `header:"x-timeout,omitempty"`
+	Internal  string        `header:"-"`
+	private   string
+	HTracing
+}
+
+type HShadowing struct {
+	TraceID string `header:"X-Trace-Id,omitempty"`
+	*HTracing
+}
+
+type HUnmarshal struct {
+	ID     int      `header:"X-Id,req"`
+	Tags   []string `header:"X-Tags"`
+	Agent  string   `header:"User-Agent"`
+	Limit  int      `header:"X-Limit,default=10,max=100"`
+	Cursor *string  `header:"X-Cursor,nil"`
+	*HTracing
+}
+
+type HListPointers struct {
+	Tags     *[]string          `header:"X-Tags"`
+	Scopes   Optional[[]string] `header:"X-Scopes"`
+	Accept   **[2]string
+	Fallback *[]string `header:"X-Fallback,default=a"`
+}
+
+type HValidate struct {
+	Min int `header:"X-Min"`
+	Max int `header:"X-Max"`
+}
+
+func (p *HValidate) Validate() error {
+	if p.Min > p.Max {
+		return errors.New("min > max")
+	}
+	return nil
+}
+
+func TestMarshalHeader(t *testing.T) {
+	retries := 3
+	h, err := MarshalHeader(&HRequest{
+		RequestID: "r1",
+		UserAgent: "qs",
+		Accept:    []string{"text/html", "a,b", `say "hi"`, ""},
+		Retries:   &retries,
+		Internal:  "secret",
+		private:   "private",
+		HTracing:  HTracing{TraceID: "t1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := expectValues(url.Values(h), url.Values{
+		"X-Request-Id": {"r1"},
+		"User-Agent":   {"qs"},
+		"Accept":       {`text/html, "a,b", "say \"hi\"", ""`},
+		"Retries":      {"3"},
+		"X-Trace-Id":   {"t1"},
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshalHeaderShadowedEmbeddedFields(t *testing.T) {
+	h, err := MarshalHeader(&HShadowing{
+		HTracing: &HTracing{TraceID: "inner", SpanID: "s1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := expectValues(url.Values(h), url.Values{
+		"X-Span-Id": {"s1"},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	h, err = MarshalHeader(&HShadowing{TraceID: "outer"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := expectValues(url.Values(h), url.Values{
+		"X-Trace-Id": {"outer"},
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalHeader(t *testing.T) {
+	var v HUnmarshal
+	err := UnmarshalHeader(&v, http.Header{
+		"x-id":       {"42"},
+		"X-TAGS":     {`a, "b,c" ,, d`, `"e \"f\""`},
+		"User-Agent": {"qs/1.0", "(linux)"},
+		"X-Trace-Id": {"t1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cr := &comparisonResults{}
+	cr.compare("ID", v.ID, 42)
+	cr.compare("Tags", v.Tags, []string{"a", "b,c", "d", `e "f"`})
+	cr.compare("Agent", v.Agent, "qs/1.0, (linux)")
+	cr.compare("Limit", v.Limit, 10)
+	cr.compare("Cursor", v.Cursor == nil, true)
+	cr.compare("HTracing != nil", v.HTracing != nil, true)
+	if v.HTracing != nil {
+		cr.compare("TraceID", v.TraceID, "t1")
+	}
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshalUnmarshalHeaderRoundTrip(t *testing.T) {
+	retries := 0
+	in := &HRequest{
+		RequestID: "r1",
+		Accept:    []string{" padded ", "a,b", `"quoted"`, `back\slash`, ""},
+		Retries:   &retries,
+		Timeout:   time.Second,
+		HTracing:  HTracing{TraceID: "t1", SpanID: "s1"},
+	}
+	h, err := MarshalHeader(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out HRequest
+	if err := UnmarshalHeader(&out, h); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cr := &comparisonResults{}
+	cr.compare("RequestID", out.RequestID, in.RequestID)
+	cr.compare("Accept", out.Accept, in.Accept)
+	cr.compare("Retries != nil", out.Retries != nil, true)
+	if out.Retries != nil {
+		cr.compare("Retries", *out.Retries, retries)
+	}
+	cr.compare("Timeout", out.Timeout, in.Timeout)
+	cr.compare("HTracing", out.HTracing, in.HTracing)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeaderListPointers(t *testing.T) {
+	tags := []string{"a", "b,c"}
+	accept := &[2]string{"x", "y"}
+	h, err := MarshalHeader(&HListPointers{
+		Tags:   &tags,
+		Scopes: Some([]string{"read", "write"}),
+		Accept: &accept,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := expectValues(url.Values(h), url.Values{
+		"X-Tags":   {`a, "b,c"`},
+		"X-Scopes": {"read, write"},
+		"Accept":   {"x, y"},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	var v HListPointers
+	err = UnmarshalHeader(&v, http.Header{
+		"X-Tags":   {"a, b", "c"},
+		"X-Scopes": {"read, write"},
+		"Accept":   {"x, y"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cr := &comparisonResults{}
+	cr.compare("Tags != nil", v.Tags != nil, true)
+	if v.Tags != nil {
+		cr.compare("Tags", *v.Tags, []string{"a", "b", "c"})
+	}
+	cr.compare("Scopes.Present", v.Scopes.Present, true)
+	cr.compare("Scopes.Value", v.Scopes.Value, []string{"read", "write"})
+	cr.compare("Accept != nil", v.Accept != nil && *v.Accept != nil, true)
+	if v.Accept != nil && *v.Accept != nil {
+		cr.compare("Accept", (**v.Accept)[:], []string{"x", "y"})
+	}
+	cr.compare("Fallback != nil", v.Fallback != nil, true)
+	if v.Fallback != nil {
+		cr.compare("Fallback", *v.Fallback, []string{"a"})
+	}
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+type HAbsent struct {
+	N     *int     `header:"X-N"`
+	Nil   *int     `header:"X-Nil,nil"`
+	Opt   *string  `header:"X-Opt,opt"`
+	Tags  []string `header:"X-Tags,opt"`
+	Limit *int     `header:"X-Limit,default=5"`
+}
+
+func TestUnmarshalHeaderAbsent(t *testing.T) {
+	var v HAbsent
+	if err := UnmarshalHeader(&v, http.Header{"X-Other": {"1"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cr := &comparisonResults{}
+	cr.compare("N", v.N == nil, true)
+	cr.compare("Nil", v.Nil == nil, true)
+	cr.compare("Opt", v.Opt == nil, true)
+	cr.compare("Tags", v.Tags == nil, true)
+	cr.compare("Limit != nil", v.Limit != nil, true)
+	if v.Limit != nil {
+		cr.compare("Limit", *v.Limit, 5)
+	}
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalHeaderErrors(t *testing.T) {
+	var v HUnmarshal
+	err := UnmarshalHeader(&v, http.Header{})
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("got error %v, want a *FieldError", err)
+	}
+	if fe.Field != "ID" || fe.Key != "X-Id" || !errors.Is(err, ErrRequiredField) {
+		t.Errorf("got error %#v, want a required field error of field ID with key X-Id", fe)
+	}
+
+	u := NewUnmarshaler(&UnmarshalOptions{CollectErrors: true})
+	err = u.UnmarshalHeader(&v, http.Header{
+		"X-Id":    {"1", "2"},
+		"X-Limit": {"101"},
+	})
+	var me *MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("got error %v, want a *MultiError", err)
+	}
+	var keys []string
+	for _, fe := range me.FieldErrors() {
+		keys = append(keys, fe.Key)
+	}
+	if got := strings.Join(keys, ","); got != "X-Id,X-Limit" {
+		t.Errorf("got errors for keys %v, want X-Id,X-Limit", got)
+	}
+
+	err = UnmarshalHeader(&HValidate{}, http.Header{"X-Min": {"2"}, "X-Max": {"1"}})
+	var he *HookError
+	if !errors.As(err, &he) || he.Method != "Validate" {
+		t.Errorf("got error %v, want a Validate *HookError", err)
+	}
+}
+
+func TestHeaderUnsupportedTypes(t *testing.T) {
+	type nested struct {
+		Filter struct{ Status string }
+	}
+	type remain struct {
+		Rest url.Values `header:",remain"`
+	}
+	type duplicate struct {
+		A string `header:"X-A"`
+		B string `header:"x-a"`
+	}
+	type Recursive struct {
+		Name string
+		*Recursive
+	}
+
+	for _, v := range []interface{}{&nested{}, &remain{}, &duplicate{}, &Recursive{}, &map[string]string{}} {
+		if _, err := MarshalHeader(v); err == nil {
+			t.Errorf("MarshalHeader(%T) succeeded, want error", v)
+		}
+		if err := UnmarshalHeader(v, http.Header{}); err == nil {
+			t.Errorf("UnmarshalHeader(%T) succeeded, want error", v)
+		}
+	}
+}
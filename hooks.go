@@ -0,0 +1,106 @@
+package qs
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// BeforeMarshalQS is an interface that can be implemented by struct types
+// that want to normalize or derive the values of their fields before they are
+// marshaled. The method is called by the marshaler of top-level and nested
+// structs before marshaling their fields.
+//
+// The method is always called on a shallow copy of the struct and that copy
+// gets marshaled so the changes made by the method don't modify the value
+// passed to the marshaler and concurrent marshal calls on the same value don't
+// race. The copy shares the data referenced by the pointer, slice and map
+// fields of the original: the method shouldn't modify that data.
+type BeforeMarshalQS interface {
+	BeforeMarshalQS(opts *MarshalOptions) error
+}
+
+// AfterUnmarshalQS is an interface that can be implemented by struct types
+// that want to post-process their fields after they have been unmarshaled.
+// The method is called by the unmarshaler of top-level and nested structs
+// after unmarshaling all of their fields without errors.
+type AfterUnmarshalQS interface {
+	AfterUnmarshalQS(opts *UnmarshalOptions) error
+}
+
+// Validate is an interface that can be implemented by struct types that want
+// to validate their unmarshaled fields together (e.g.: from <= to). The
+// Validate method is called by the unmarshaler after AfterUnmarshalQS.
+type Validate interface {
+	Validate() error
+}
+
+var (
+	beforeMarshalQSInterfaceType  = reflect.TypeOf((*BeforeMarshalQS)(nil)).Elem()
+	afterUnmarshalQSInterfaceType = reflect.TypeOf((*AfterUnmarshalQS)(nil)).Elem()
+	validateInterfaceType         = reflect.TypeOf((*Validate)(nil)).Elem()
+)
+
+// HookError is returned when the BeforeMarshalQS, AfterUnmarshalQS or
+// Validate method of a struct returns an error. The unmarshaler wraps the
+// HookError of a nested struct into a *FieldError just like any other error
+// of a nested value.
+type HookError struct {
+	// Struct is the type of the struct whose method failed.
+	Struct reflect.Type
+	// Method is the name of the failed method. E.g.: "Validate".
+	Method string
+	// Err is the error returned by the method.
+	Err error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%v.%v failed :: %v", e.Struct, e.Method, e.Err)
+}
+
+// Unwrap returns the error returned by the method.
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// implementsHook returns true if the values of type t or their addresses
+// implement the given interface.
+func implementsHook(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+// hookReceiver returns the value on which the methods of a hook interface
+// can be called: the address of v if it is addressable, otherwise v itself.
+func hookReceiver(v reflect.Value) interface{} {
+	if v.CanAddr() {
+		return v.Addr().Interface()
+	}
+	return v.Interface()
+}
+
+// callBeforeMarshal calls the BeforeMarshalQS method of an addressable copy of
+// the struct value v and returns the copy to be marshaled.
+func callBeforeMarshal(v reflect.Value, opts *MarshalOptions) (reflect.Value, error) {
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	v = c
+	if err := hookReceiver(v).(BeforeMarshalQS).BeforeMarshalQS(opts); err != nil {
+		return v, &HookError{Struct: v.Type(), Method: "BeforeMarshalQS", Err: err}
+	}
+	return v, nil
+}
+
+// callAfterUnmarshal calls the AfterUnmarshalQS and Validate methods of the
+// struct value v if its type implements them.
+func callAfterUnmarshal(v reflect.Value, opts *UnmarshalOptions, after, validate bool) error {
+	if after {
+		if err := hookReceiver(v).(AfterUnmarshalQS).AfterUnmarshalQS(opts); err != nil {
+			return &HookError{Struct: v.Type(), Method: "AfterUnmarshalQS", Err: err}
+		}
+	}
+	if validate {
+		if err := hookReceiver(v).(Validate).Validate(); err != nil {
+			return &HookError{Struct: v.Type(), Method: "Validate", Err: err}
+		}
+	}
+	return nil
+}
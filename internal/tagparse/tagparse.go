@@ -0,0 +1,278 @@
+// Package tagparse parses the qs and header struct tags of the
+// github.com/pasztorpisti/qs package. The qs package, its code generator and
+// its analyzer share this parser so they accept and reject the same tags.
+//
+// The parser checks only the syntax of the tags. The checks that depend on
+// the type of the field (e.g.: a time layout option on a non-time.Time field)
+// are left to the users of the package.
+package tagparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Tag holds the options of a struct tag. The options that have a fixed set of
+// values are stored with their names used in the tags (e.g.: "omitempty").
+type Tag struct {
+	// Name is the key of the field. It is empty if the tag doesn't specify
+	// it and "-" if the field is skipped.
+	Name string
+	// Options holds the options of the tag after the name in the order of
+	// the tag (e.g.: "omitempty" or "min=1").
+	Options []string
+
+	// MarshalPresence is keepempty, omitempty, omitdefault or empty if the
+	// tag doesn't have such an option.
+	MarshalPresence string
+	// UnmarshalPresence is nil, opt, req or empty if the tag doesn't have
+	// such an option.
+	UnmarshalPresence string
+	// Style is the value of the style option or empty.
+	Style string
+	// Explode is the value of the explode option ("true" or "false") or
+	// empty.
+	Explode string
+	// Default is the value of the default option. It is valid only if
+	// HasDefault is true.
+	Default    string
+	HasDefault bool
+	// Constraints holds the constraint options in the order of the tag.
+	Constraints []Constraint
+	// Remain is true if the tag has the remain option.
+	Remain bool
+	// TimeLayout is the value of the layout option or "unix" or "unixmilli"
+	// in case of the unix and unixmilli options. It is empty if the tag
+	// doesn't have any of these options.
+	TimeLayout string
+	// In holds the request sources listed by the in option (query, form,
+	// path and header). It is nil if the tag doesn't have an in option.
+	In []string
+}
+
+// Styles are the valid values of the style option.
+var Styles = []string{"form", "spaceDelimited", "pipeDelimited", "deepObject"}
+
+// Sources are the valid items of the in option.
+var Sources = []string{"query", "form", "path", "header"}
+
+// Parse parses the value of a qs or header struct tag. The options are
+// separated by commas except for the pattern option that takes the rest of
+// the tag so it has to be the last option.
+func Parse(v string) (tag Tag, err error) {
+	arr := strings.Split(v, ",")
+	tag.Name = arr[0]
+	tag.Options = arr[1:]
+	// The pattern option takes the rest of the tag because regular
+	// expressions can contain commas (e.g.: "pattern=^[0-9]{1,3}$").
+	for i, option := range tag.Options {
+		if strings.HasPrefix(option, "pattern=") {
+			tag.Options = append(tag.Options[:i:i], strings.Join(tag.Options[i:], ","))
+			break
+		}
+	}
+
+	setPresence := func(kind string, p *string, v string) error {
+		if *p != "" {
+			return fmt.Errorf("only one %v option is allowed - you've specified at least two: %v, %v", kind, *p, v)
+		}
+		*p = v
+		return nil
+	}
+
+	for _, option := range tag.Options {
+		if i := strings.IndexByte(option, '='); i >= 0 {
+			err = tag.parseValueOption(option[:i], option[i+1:])
+			if err != nil {
+				return
+			}
+			continue
+		}
+		switch option {
+		case "nil", "opt", "req":
+			err = setPresence("UnmarshalPresence", &tag.UnmarshalPresence, option)
+		case "keepempty", "omitempty", "omitdefault":
+			err = setPresence("MarshalPresence", &tag.MarshalPresence, option)
+		case "remain":
+			tag.Remain = true
+		case "unix", "unixmilli":
+			err = tag.setTimeLayout(option)
+		case "":
+			err = errors.New("tag string contains a surplus comma")
+		default:
+			err = fmt.Errorf("invalid option in field tag: %q", option)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	if tag.HasDefault && tag.UnmarshalPresence == "req" {
+		err = errors.New("the default option can't be combined with the req option")
+	} else if tag.MarshalPresence == "omitdefault" && !tag.HasDefault {
+		err = errors.New("the omitdefault option requires a default option")
+	}
+	return
+}
+
+// parseValueOption parses the tag options that have a value in "key=value"
+// format.
+func (tag *Tag) parseValueOption(key, value string) error {
+	switch key {
+	case "style":
+		if tag.Style != "" {
+			return errors.New("the style option is specified more than once")
+		}
+		if !contains(Styles, value) {
+			return fmt.Errorf("invalid style: %q", value)
+		}
+		tag.Style = value
+	case "default":
+		if tag.HasDefault {
+			return errors.New("the default option is specified more than once")
+		}
+		tag.Default = value
+		tag.HasDefault = true
+	case "layout":
+		return tag.setTimeLayout(value)
+	case "in":
+		if tag.In != nil {
+			return errors.New("the in option is specified more than once")
+		}
+		in, err := ParseIn(value)
+		if err != nil {
+			return err
+		}
+		tag.In = in
+	case "explode":
+		if tag.Explode != "" {
+			return errors.New("the explode option is specified more than once")
+		}
+		if value != "true" && value != "false" {
+			return fmt.Errorf("invalid explode value: %q", value)
+		}
+		tag.Explode = value
+	default:
+		c, ok, err := ParseConstraint(key, value)
+		if !ok {
+			return fmt.Errorf("invalid option in field tag: %q", key+"="+value)
+		}
+		if err != nil {
+			return err
+		}
+		tag.Constraints = append(tag.Constraints, c)
+	}
+	return nil
+}
+
+// setTimeLayout stores the layout of a layout, unix or unixmilli tag option.
+func (tag *Tag) setTimeLayout(layout string) error {
+	if tag.TimeLayout != "" {
+		return errors.New("only one of the layout, unix and unixmilli options is allowed")
+	}
+	if layout == "" {
+		return errors.New("the layout option requires a non-empty layout")
+	}
+	tag.TimeLayout = layout
+	return nil
+}
+
+// Constraint is a validation rule parsed from a "key=value" option of a
+// field tag. The value constraints (min, max, pattern and oneof) are checked
+// against every item of array and slice fields while the length constraints
+// (len and maxlen) are checked against the length of strings, arrays and
+// slices.
+type Constraint struct {
+	// Name is the key of the tag option. E.g.: "min".
+	Name string
+	// Param is the raw value of the tag option. E.g.: "1".
+	Param string
+
+	// Num is the parameter of the min and max constraints.
+	Num float64
+	// Length is the parameter of the len and maxlen constraints.
+	Length int
+	// Pattern is the parameter of the pattern constraint. It isn't anchored:
+	// it has to match only a substring of the value like the pattern
+	// keyword of OpenAPI 3.
+	Pattern *regexp.Regexp
+	// OneOf is the parameter of the oneof constraint.
+	OneOf []string
+}
+
+// ParseConstraint parses a "key=value" tag option. It returns ok==false if
+// the option isn't a constraint.
+func ParseConstraint(key, value string) (c Constraint, ok bool, err error) {
+	c = Constraint{Name: key, Param: value}
+	switch key {
+	case "min", "max":
+		c.Num, err = strconv.ParseFloat(value, 64)
+	case "len", "maxlen":
+		c.Length, err = strconv.Atoi(value)
+		if err == nil && c.Length < 0 {
+			err = fmt.Errorf("negative length: %v", c.Length)
+		}
+	case "pattern":
+		c.Pattern, err = regexp.Compile(value)
+	case "oneof":
+		c.OneOf = strings.Split(value, "|")
+	default:
+		return c, false, nil
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid %v option :: %w", key, err)
+	}
+	return c, true, err
+}
+
+// ParseIn parses the value of an in option: a pipe separated list of
+// request sources (e.g.: "query|header").
+func ParseIn(value string) ([]string, error) {
+	in := []string{}
+	for _, name := range strings.Split(value, "|") {
+		if !contains(Sources, name) {
+			return nil, fmt.Errorf("invalid in value: %q", value)
+		}
+		if contains(in, name) {
+			return nil, fmt.Errorf("the in option lists %q more than once", name)
+		}
+		in = append(in, name)
+	}
+	return in, nil
+}
+
+func contains(a []string, s string) bool {
+	for _, item := range a {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SnakeCase converts CamelCase names to snake_case with lowercase letters and
+// underscores. Names already in snake_case are left untouched. It is the
+// default name transformer of the qs package.
+func SnakeCase(s string) string {
+	in := []rune(s)
+	isLower := func(idx int) bool {
+		return idx >= 0 && idx < len(in) && unicode.IsLower(in[idx])
+	}
+
+	out := make([]rune, 0, len(in)+len(in)/2)
+	for i, r := range in {
+		if unicode.IsUpper(r) {
+			r = unicode.ToLower(r)
+			if i > 0 && in[i-1] != '_' && (isLower(i-1) || isLower(i+1)) {
+				out = append(out, '_')
+			}
+		}
+		out = append(out, r)
+	}
+
+	return string(out)
+}
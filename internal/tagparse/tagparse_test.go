@@ -0,0 +1,83 @@
+package tagparse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tag, err := Parse("ids,omitempty,req,style=pipeDelimited,explode=false,min=1,oneof=1|2,in=query|header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Name != "ids" || tag.MarshalPresence != "omitempty" || tag.UnmarshalPresence != "req" ||
+		tag.Style != "pipeDelimited" || tag.Explode != "false" {
+		t.Errorf("unexpected tag: %+v", tag)
+	}
+	if len(tag.Constraints) != 2 || tag.Constraints[0].Num != 1 || !reflect.DeepEqual(tag.Constraints[1].OneOf, []string{"1", "2"}) {
+		t.Errorf("unexpected constraints: %+v", tag.Constraints)
+	}
+	if !reflect.DeepEqual(tag.In, []string{"query", "header"}) {
+		t.Errorf("In == %v, want [query header]", tag.In)
+	}
+
+	tag, err = Parse(",unixmilli,default=0,remain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.TimeLayout != "unixmilli" || !tag.HasDefault || tag.Default != "0" || !tag.Remain {
+		t.Errorf("unexpected tag: %+v", tag)
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	tag, err := Parse("zip,opt,pattern=^[0-9]{4,5}$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.UnmarshalPresence != "opt" || len(tag.Constraints) != 1 || tag.Constraints[0].Param != "^[0-9]{4,5}$" {
+		t.Fatalf("unexpected tag: %+v", tag)
+	}
+	if !reflect.DeepEqual(tag.Options, []string{"opt", "pattern=^[0-9]{4,5}$"}) {
+		t.Errorf("Options == %q", tag.Options)
+	}
+
+	// The rest of the tag belongs to the pattern that isn't anchored implicitly.
+	tag, err = Parse(",pattern=[0-9],omitempty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.MarshalPresence != "" || tag.Constraints[0].Param != "[0-9],omitempty" {
+		t.Errorf("unexpected tag: %+v", tag)
+	}
+	if p := tag.Constraints[0].Pattern; !p.MatchString("a1,omitempty") || p.MatchString("1") {
+		t.Errorf("unexpected pattern matching: %v", p)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for tagStr, want := range map[string]string{
+		"name,":                  "surplus comma",
+		",omitemtpy":             "invalid option in field tag",
+		",opt,req":               "only one UnmarshalPresence option",
+		",keepempty,omitempty":   "only one MarshalPresence option",
+		",default=1,req":         "can't be combined with the req option",
+		",omitdefault":           "requires a default option",
+		",style=matrix":          "invalid style",
+		",style=form,style=form": "the style option is specified more than once",
+		",explode=yes":           "invalid explode value",
+		",unix,layout=2006":      "only one of the layout, unix and unixmilli options",
+		",layout=":               "requires a non-empty layout",
+		",in=body":               "invalid in value",
+		",in=query|query":        "lists \"query\" more than once",
+		",maxlen=-1":             "invalid maxlen option",
+		",pattern=[":             "invalid pattern option",
+		",minimum=1":             "invalid option in field tag",
+	} {
+		_, err := Parse(tagStr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error == %v, want %q", tagStr, err, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package qs
+
+// JSCompatOptions is used as a parameter by the NewJSCompatMarshaler and
+// NewJSCompatUnmarshaler functions. The fields correspond to the options of
+// the stringify and parse functions of the JavaScript qs library.
+type JSCompatOptions struct {
+	// ArrayFormat corresponds to the arrayFormat option of qs.stringify.
+	// If this field is AFUnspecified then ArrayIndices is used just like in
+	// the JavaScript qs library. Arrays and slices of structs are always
+	// marshaled with indices.
+	ArrayFormat ArrayFormat
+
+	// AllowDots corresponds to the allowDots option of qs.stringify and
+	// qs.parse. If it is true then the keys of nested values are joined with
+	// DotSyntax otherwise with BracketSyntax.
+	AllowDots bool
+
+	// Depth corresponds to the depth option of qs.parse. If this field is
+	// zero then a default of 5 is used. A negative value turns off the limit.
+	Depth int
+
+	// ParameterLimit corresponds to the parameterLimit option of qs.parse.
+	// If this field is zero then a default of 1000 is used. A negative value
+	// turns off the limit.
+	ParameterLimit int
+}
+
+const (
+	defaultJSCompatDepth          = 5
+	defaultJSCompatParameterLimit = 1000
+)
+
+// NewJSCompatMarshaler creates a marshaler that produces query strings in the
+// format of the stringify function of the JavaScript qs library with the
+// given options. The opts parameter can be nil.
+//
+// The output isn't identical to that of qs.stringify in two ways:
+//   - The Marshal method of the marshaler sorts the keys while qs.stringify
+//     keeps the order of the object properties. AppendMarshal writes the
+//     keys of structs in a fixed order (see AppendMarshal) that is the order
+//     of qs.stringify only for structs without embedded and remain fields.
+//   - Arrays and slices of structs, arrays and slices are always marshaled
+//     with indices (e.g.: "items[0][name]=a") even if ArrayFormat is
+//     ArrayBrackets or ArrayRepeat, in which case qs.stringify would write
+//     "items[][name]=a" or "items[name]=a". qs.parse turns the indexed keys
+//     into the same arrays while it can't restore the arrays of objects
+//     from the other two formats.
+func NewJSCompatMarshaler(opts *JSCompatOptions) *QSMarshaler {
+	jsOpts := prepareJSCompatOptions(opts)
+	mopts := &MarshalOptions{
+		KeySyntax:   jsOpts.keySyntax(),
+		ArrayFormat: jsOpts.ArrayFormat,
+		SpaceEscape: SpaceAsPercent20,
+	}
+	return NewMarshaler(mopts)
+}
+
+// NewJSCompatUnmarshaler creates an unmarshaler that accepts the same query
+// strings as the parse function of the JavaScript qs library with the given
+// options. The opts parameter can be nil.
+//
+// Like qs.parse, the unmarshaler keeps the segments of keys nested deeper than
+// the depth limit as a single literal key (see UnmarshalOptions.MaxDepth).
+//
+// The qs.parse function compacts sparse arrays and turns the arrays with
+// indices above its arrayLimit option into objects with numeric keys. Both
+// are unmarshaled into Go arrays and slices as items in the order of their
+// indices (see UnmarshalOptions.CompactSlices) so the unmarshaler has no
+// array limit option and it accepts the output of NewJSCompatMarshaler
+// regardless of the length of the marshaled slices.
+func NewJSCompatUnmarshaler(opts *JSCompatOptions) *QSUnmarshaler {
+	jsOpts := prepareJSCompatOptions(opts)
+	uopts := &UnmarshalOptions{
+		KeySyntax:     jsOpts.keySyntax(),
+		ArrayFormat:   jsOpts.ArrayFormat,
+		CompactSlices: true,
+	}
+	if jsOpts.Depth > 0 {
+		uopts.MaxDepth = jsOpts.Depth
+	}
+	if jsOpts.ParameterLimit > 0 {
+		uopts.ParameterLimit = jsOpts.ParameterLimit
+	}
+	return NewUnmarshaler(uopts)
+}
+
+func prepareJSCompatOptions(opts *JSCompatOptions) JSCompatOptions {
+	var jsOpts JSCompatOptions
+	if opts != nil {
+		jsOpts = *opts
+	}
+	if jsOpts.ArrayFormat == AFUnspecified {
+		jsOpts.ArrayFormat = ArrayIndices
+	}
+	if jsOpts.Depth == 0 {
+		jsOpts.Depth = defaultJSCompatDepth
+	}
+	if jsOpts.ParameterLimit == 0 {
+		jsOpts.ParameterLimit = defaultJSCompatParameterLimit
+	}
+	return jsOpts
+}
+
+func (p *JSCompatOptions) keySyntax() KeySyntax {
+	if p.AllowDots {
+		return DotSyntax
+	}
+	return BracketSyntax
+}
@@ -0,0 +1,224 @@
+package qs
+
+import (
+	"testing"
+)
+
+type JSInner struct {
+	C string
+}
+
+type JSNested struct {
+	B JSInner
+}
+
+type JSObj struct {
+	A []string  `qs:",omitempty"`
+	N *JSNested `qs:",omitempty"`
+}
+
+func TestJSCompatMarshal(t *testing.T) {
+	v := &JSObj{A: []string{"b", "c"}}
+	for _, tc := range []struct {
+		format ArrayFormat
+		want   string
+	}{
+		{AFUnspecified, "a%5B0%5D=b&a%5B1%5D=c"},
+		{ArrayIndices, "a%5B0%5D=b&a%5B1%5D=c"},
+		{ArrayBrackets, "a%5B%5D=b&a%5B%5D=c"},
+		{ArrayRepeat, "a=b&a=c"},
+		{ArrayComma, "a=b%2Cc"},
+	} {
+		marshaler := NewJSCompatMarshaler(&JSCompatOptions{ArrayFormat: tc.format})
+		queryStr, err := marshaler.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if queryStr != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.format, queryStr, tc.want)
+		}
+	}
+}
+
+func TestJSCompatMarshalNested(t *testing.T) {
+	v := &JSObj{N: &JSNested{B: JSInner{C: "d e"}}}
+
+	queryStr, err := NewJSCompatMarshaler(nil).Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "n%5Bb%5D%5Bc%5D=d%20e"; queryStr != want {
+		t.Errorf("got %q, want %q", queryStr, want)
+	}
+
+	queryStr, err = NewJSCompatMarshaler(&JSCompatOptions{AllowDots: true}).Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "n.b.c=d%20e"; queryStr != want {
+		t.Errorf("got %q, want %q", queryStr, want)
+	}
+}
+
+func TestJSCompatMarshalStructSlices(t *testing.T) {
+	v := &struct {
+		Items []JSInner
+	}{Items: []JSInner{{C: "a"}, {C: "b"}}}
+
+	// Unlike qs.stringify, the marshaler writes indices regardless of the
+	// array format.
+	for _, format := range []ArrayFormat{ArrayIndices, ArrayBrackets, ArrayRepeat} {
+		marshaler := NewJSCompatMarshaler(&JSCompatOptions{ArrayFormat: format})
+		queryStr, err := marshaler.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "items%5B0%5D%5Bc%5D=a&items%5B1%5D%5Bc%5D=b"; queryStr != want {
+			t.Errorf("%v: got %q, want %q", format, queryStr, want)
+		}
+	}
+}
+
+func TestJSCompatUnmarshalArrayFormats(t *testing.T) {
+	unmarshaler := NewJSCompatUnmarshaler(nil)
+	for _, queryStr := range []string{
+		"a[0]=b&a[1]=c",
+		"a[1]=c&a[0]=b",
+		"a[]=b&a[]=c",
+		"a=b&a=c",
+	} {
+		var v JSObj
+		if err := unmarshaler.Unmarshal(&v, queryStr); err != nil {
+			t.Errorf("query string %q :: %v", queryStr, err)
+			continue
+		}
+		var cr comparisonResults
+		cr.compare("a", v.A, []string{"b", "c"})
+		if err := cr.finish(); err != nil {
+			t.Errorf("query string %q :: %v", queryStr, err)
+		}
+	}
+
+	// qs.parse compacts sparse arrays and turns the arrays with indices above
+	// its array limit into objects with numeric keys.
+	var v JSObj
+	if err := unmarshaler.Unmarshal(&v, "a[21]=c&a[3]=b"); err != nil {
+		t.Fatal(err)
+	}
+	var cr comparisonResults
+	cr.compare("a", v.A, []string{"b", "c"})
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	unmarshaler = NewJSCompatUnmarshaler(&JSCompatOptions{ArrayFormat: ArrayComma})
+	v = JSObj{}
+	if err := unmarshaler.Unmarshal(&v, "a=b,c"); err != nil {
+		t.Fatal(err)
+	}
+	cr = comparisonResults{}
+	cr.compare("a", v.A, []string{"b", "c"})
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSCompatUnmarshalLimits(t *testing.T) {
+	var v struct {
+		A string
+		C string
+		N JSNested
+		D struct {
+			E struct {
+				F struct {
+					G struct {
+						H struct {
+							I string
+							J struct {
+								K string
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	unmarshaler := NewJSCompatUnmarshaler(&JSCompatOptions{
+		AllowDots:      true,
+		ParameterLimit: 4,
+	})
+	err := unmarshaler.Unmarshal(&v, "a=1&n.b[c]=2&d.e.f.g.h.i=3&d.e.f.g.h.j.k=4&c=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr comparisonResults
+	cr.compare("a", v.A, "1")
+	cr.compare("n.b.c", v.N.B.C, "2")
+	cr.compare("d.e.f.g.h.i", v.D.E.F.G.H.I, "3")
+	cr.compare("d.e.f.g.h.j.k", v.D.E.F.G.H.J.K, "")
+	cr.compare("c", v.C, "")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSCompatRoundTripLongSlices(t *testing.T) {
+	type item struct {
+		ID int
+	}
+	type obj struct {
+		Ints  []int
+		Items []item
+	}
+	in := obj{}
+	for i := 0; i < 25; i++ {
+		in.Ints = append(in.Ints, i)
+		in.Items = append(in.Items, item{ID: i})
+	}
+
+	for _, opts := range []*JSCompatOptions{nil, {AllowDots: true}} {
+		queryStr, err := NewJSCompatMarshaler(opts).Marshal(&in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out obj
+		if err := NewJSCompatUnmarshaler(opts).Unmarshal(&out, queryStr); err != nil {
+			t.Fatalf("query string %q :: %v", queryStr, err)
+		}
+		var cr comparisonResults
+		cr.compare("ints", out.Ints, in.Ints)
+		cr.compare("items", out.Items, in.Items)
+		if err := cr.finish(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestJSCompatUnmarshalDepthLiteral(t *testing.T) {
+	var v struct {
+		A struct {
+			B map[string]string
+		}
+	}
+	unmarshaler := NewJSCompatUnmarshaler(&JSCompatOptions{Depth: 1})
+	err := unmarshaler.Unmarshal(&v, "a[b][c][d][e]=1&a[b][f]=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr comparisonResults
+	cr.compare("a.b.[c][d][e]", v.A.B["[c][d][e]"], "1")
+	cr.compare("a.b.[f]", v.A.B["[f]"], "2")
+	cr.compare("len(a.b)", len(v.A.B), 2)
+
+	v.A.B = nil
+	unmarshaler = NewJSCompatUnmarshaler(&JSCompatOptions{Depth: 1, AllowDots: true})
+	err = unmarshaler.Unmarshal(&v, "a.b.c.d[e]=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr.compare("dots a.b.[c][d][e]", v.A.B["[c][d][e]"], "1")
+	cr.compare("dots len(a.b)", len(v.A.B), 1)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
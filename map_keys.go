@@ -0,0 +1,76 @@
+package qs
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// checkMapKeyType returns an error if the marshaler and unmarshaler can't
+// convert the keys of maps of type t from and to strings. Map keys can be of
+// any string or integer kind and of any type that implements
+// encoding.TextMarshaler and encoding.TextUnmarshaler.
+func checkMapKeyType(t reflect.Type, marshal bool) error {
+	kt := t.Key()
+	if marshal && reflect.PtrTo(kt).Implements(textMarshalerInterfaceType) {
+		return nil
+	}
+	if !marshal && reflect.PtrTo(kt).Implements(textUnmarshalerInterfaceType) {
+		return nil
+	}
+	switch kt.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	}
+	return fmt.Errorf("map key type is expected to be a string, an integer or a text marshaler: %v", t)
+}
+
+// marshalMapKey converts a map key into the string used in the query string.
+func marshalMapKey(key reflect.Value) (string, error) {
+	t := key.Type()
+	if reflect.PtrTo(t).Implements(textMarshalerInterfaceType) {
+		s, err := marshalWithMarshalText(key, nil)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling map key %v :: %w", key, err)
+		}
+		return s, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return key.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	default:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+}
+
+// unmarshalMapKey converts a key of the query string into a map key of
+// type t.
+func unmarshalMapKey(s string, t reflect.Type) (reflect.Value, error) {
+	key := reflect.New(t).Elem()
+	if reflect.PtrTo(t).Implements(textUnmarshalerInterfaceType) {
+		err := key.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		return key, err
+	}
+	switch t.Kind() {
+	case reflect.String:
+		key.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return key, err
+		}
+		key.SetInt(i)
+	default:
+		u, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return key, err
+		}
+		key.SetUint(u)
+	}
+	return key, nil
+}
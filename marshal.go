@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"strings"
 )
 
 // MarshalPresence is an enum that controls the marshaling of empty fields.
@@ -23,6 +24,13 @@ const (
 
 	// OmitEmpty doesn't marshal the values of empty fields into the marshal output.
 	OmitEmpty
+
+	// OmitDefault doesn't marshal the values of fields that are equal to the
+	// default value in their tags. The omitdefault tag option requires a
+	// default option but when OmitDefault is used as the
+	// DefaultMarshalPresence the values of fields without a default value are
+	// omitted when they are empty.
+	OmitDefault
 )
 
 func (v MarshalPresence) String() string {
@@ -35,11 +43,44 @@ func (v MarshalPresence) String() string {
 	case OmitEmpty:
 		// using lowercase to match the format used in struct tags
 		return "omitempty"
+	case OmitDefault:
+		// using lowercase to match the format used in struct tags
+		return "omitdefault"
 	default:
 		return fmt.Sprintf("MarshalPresence(%v)", int(v))
 	}
 }
 
+// SpaceEscape is an enum that controls how spaces are escaped in query strings.
+type SpaceEscape int
+
+const (
+	// SEUnspecified is the zero value of SpaceEscape. In most cases you will
+	// use this implicitly by simply leaving the MarshalOptions.SpaceEscape
+	// field uninitialised which results in using the default SpaceEscape
+	// which is SpaceAsPlus.
+	SEUnspecified SpaceEscape = iota
+
+	// SpaceAsPlus escapes spaces as "+" like url.Values.Encode.
+	SpaceAsPlus
+
+	// SpaceAsPercent20 escapes spaces as "%20" as defined by RFC 3986.
+	SpaceAsPercent20
+)
+
+func (v SpaceEscape) String() string {
+	switch v {
+	case SEUnspecified:
+		return "SEUnspecified"
+	case SpaceAsPlus:
+		return "SpaceAsPlus"
+	case SpaceAsPercent20:
+		return "SpaceAsPercent20"
+	default:
+		return fmt.Sprintf("SpaceEscape(%v)", int(v))
+	}
+}
+
 // MarshalOptions is used as a parameter by the NewMarshaler function.
 type MarshalOptions struct {
 	// NameTransformer is used to transform struct field names into a query
@@ -59,6 +100,44 @@ type MarshalOptions struct {
 	// a default builtin factory.
 	MarshalerFactory MarshalerFactory
 
+	// KeySyntax controls how the keys of nested struct fields are joined to
+	// the name of the field that holds them. If this field is KSUnspecified
+	// then NewMarshaler uses DotSyntax.
+	KeySyntax KeySyntax
+
+	// DefaultStyle is used for the marshaling of struct fields that don't
+	// have an explicit style option set in their tags. If this field is
+	// StyleUnspecified then NewMarshaler uses FormStyle.
+	DefaultStyle Style
+
+	// DefaultExplode is used for the marshaling of struct fields that don't
+	// have an explicit explode option set in their tags. If this field is
+	// EMUnspecified then only the arrays and slices marshaled with FormStyle
+	// are exploded.
+	DefaultExplode ExplodeMode
+
+	// ArrayFormat controls the keys of struct fields that are arrays or
+	// slices of primitive values. If this field is AFUnspecified then
+	// NewMarshaler uses ArrayRepeat.
+	ArrayFormat ArrayFormat
+
+	// SpaceEscape controls how the Marshal method of the marshaler escapes
+	// the spaces of the query string. If this field is SEUnspecified then
+	// NewMarshaler uses SpaceAsPlus.
+	SpaceEscape SpaceEscape
+
+	// TimeLayout is the layout used to format time.Time values (see the
+	// Format method of time.Time). It can also be UnixTimeLayout or
+	// UnixMilliTimeLayout. The layout, unix and unixmilli tag options
+	// override it for a single field. If this field is empty then
+	// NewMarshaler uses time.RFC3339.
+	TimeLayout string
+
+	// DurationString marshals time.Duration values in the format of their
+	// String method (e.g.: "1.5s") instead of integer nanoseconds
+	// (e.g.: "1500000000"). The unmarshaler accepts both formats.
+	DurationString bool
+
 	// DefaultMarshalPresence is used for the marshaling of struct fields that
 	// don't have an explicit MarshalPresence option set in their tags.
 	// This option is used for every item when you marshal a map[string]WhateverType
@@ -75,9 +154,10 @@ var DefaultMarshaler = NewMarshaler(&MarshalOptions{})
 // structs and maps satisfy this condition without using a custom
 // ValuesMarshalerFactory.
 //
-// If you use a map then the key type has to be string or a type with string as
-// its underlying type and the map value type can be anything that can be used
-// as a struct field for marshaling.
+// If you use a map then the key type has to be a type with a string or integer
+// underlying type or a type that implements encoding.TextMarshaler (and
+// encoding.TextUnmarshaler for unmarshaling). The map value type can be
+// anything that can be used as a struct field for marshaling.
 //
 // A struct value is marshaled by adding its fields one-by-one to the query
 // string. Only exported struct fields are marshaled. The struct field tag can
@@ -89,28 +169,74 @@ var DefaultMarshaler = NewMarshaler(&MarshalOptions{})
 //  - If name is omitted then it defaults to the snake_case of the FieldName.
 //    The snake_case transformation can be replaced with a field name to query
 //    string name converter function by creating a custom marshaler.
-//  - For marshaling you can specify one of the keepempty and omitempty options.
-//    If none of them is specified then the keepempty option is the default but
-//    this default can be changed by using a custom marshaler object.
+//  - For marshaling you can specify one of the keepempty, omitempty and
+//    omitdefault options. If none of them is specified then the keepempty
+//    option is the default but this default can be changed by using a custom
+//    marshaler object. The omitdefault option requires a default=value
+//    option (see Unmarshal) and omits the value of the field if it is
+//    marshaled into the same strings as the parsed default value (e.g.:
+//    default=1.0 omits the float 1).
+//  - The style=form|spaceDelimited|pipeDelimited|deepObject and the
+//    explode=true|false options select one of the OpenAPI 3 serialization
+//    styles for the field. E.g.: `qs:"ids,style=pipeDelimited"` marshals
+//    []int{1, 2} as "ids=1|2". The defaults can be changed with the
+//    DefaultStyle and DefaultExplode marshal options.
+//  - The remain option can be used on a single url.Values or
+//    map[string][]string field of the struct. Unmarshal stores the keys that
+//    aren't consumed by the other fields into this field and Marshal merges
+//    its entries back into the output. The keys of the other fields take
+//    precedence.
+//  - The layout=layout, unix and unixmilli options of time.Time fields
+//    override the TimeLayout of the marshaler and the unmarshaler. E.g.:
+//    `qs:"from,layout=2006-01-02"` or `qs:"since,unix"`. Layouts that contain
+//    commas can be set only with the TimeLayout option.
 //
 //  Examples:
 //  FieldName bool `qs:"-"
 //  FieldName bool `qs:"name_in_query_str"
 //  FieldName bool `qs:"name_in_query_str,keepempty"
 //  FieldName bool `qs:",omitempty"
+//  FieldName []int `qs:"name_in_query_str,style=form,explode=false"
 //
 // Anonymous struct fields are marshaled as if their inner exported fields were
-// fields in the outer struct.
+// fields in the outer struct. Conflicting keys are resolved by Go's embedding
+// rules: a field of the outer struct shadows the fields of embedded structs
+// with the same key and two fields with the same key at the same embedding
+// depth (e.g.: UserID and UserId that both become user_id) make the struct
+// unsupported (see CheckMarshalType).
+//
+// Non-anonymous struct fields (and pointers to structs) that aren't supported
+// by the MarshalerFactory of the marshaler are marshaled as nested values:
+// the keys of their fields are prefixed with the name of the outer field.
+// E.g.: a Filter field that has a Status field inside is marshaled as
+// "filter.status=open" or as "filter[status]=open" depending on the KeySyntax
+// of the marshaler.
+//
+// Map fields are marshaled the same way: every map entry is marshaled into a
+// key that is prefixed with the name of the field. E.g.: a Filters field of
+// type map[string]string is marshaled as "filters[status]=open" when the
+// marshaler uses BracketSyntax.
 //
 // Pointer fields are omitted when they are nil otherwise they are marshaled as
 // the value pointed to.
 //
+// If the top-level struct or a nested struct implements the BeforeMarshalQS
+// interface then its BeforeMarshalQS method is called on a copy of the struct
+// before marshaling the fields of the copy. The error returned by the method
+// is wrapped into a *HookError.
+//
 // Items of array and slice fields are encoded by adding multiple items with the
 // same key to the query string. E.g.: arr=[]byte{1, 2} is encoded as "arr=1&arr=2".
 // You can change this behavior by creating a custom marshaler with its custom
 // MarshalerFactory that provides your custom marshal logic for the given slice
 // and/or array types.
 //
+// Arrays and slices whose items are structs, arrays or slices are marshaled
+// with explicit indices in their keys. E.g.: an Items field of type
+// []struct{ Name string } is marshaled as "items[0].name=a&items[1].name=b" or
+// as "items[0][name]=a&items[1][name]=b" depending on the KeySyntax of the
+// marshaler.
+//
 // When a field is marshaled with the omitempty option then the field is skipped
 // if it has the zero value of its type.
 // A field is marshaled with the omitempty option when its tag explicitly
@@ -149,12 +275,16 @@ func CheckMarshalType(t reflect.Type) error {
 // used to marshal structs or maps into query strings or url.Values.
 type QSMarshaler struct {
 	opts *MarshalOptions
+	// headerCache holds the header struct marshalers of the types passed to
+	// MarshalHeader.
+	headerCache syncMap
 }
 
 // NewMarshaler returns a new QSMarshaler object.
 func NewMarshaler(opts *MarshalOptions) *QSMarshaler {
 	return &QSMarshaler{
-		opts: prepareMarshalOptions(*opts),
+		opts:        prepareMarshalOptions(*opts),
+		headerCache: newSyncMap(),
 	}
 }
 
@@ -165,7 +295,18 @@ func (p *QSMarshaler) Marshal(i interface{}) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return values.Encode(), nil
+	return p.encode(values), nil
+}
+
+// encode converts the marshaled values into a query string.
+func (p *QSMarshaler) encode(values url.Values) string {
+	s := values.Encode()
+	if p.opts.SpaceEscape == SpaceAsPercent20 {
+		// Encode escapes the literal "+" characters as "%2B" so the remaining
+		// ones are all escaped spaces.
+		s = strings.Replace(s, "+", "%20", -1)
+	}
+	return s
 }
 
 // MarshalValues marshals a given object into a url.Values.
@@ -224,7 +365,9 @@ var defaultValuesMarshalerFactory = newValuesMarshalerFactory()
 // to a factory object that handles most builtin types (arrays, pointers,
 // bool, int, etc...). If a type implements the MarshalQS interface then this
 // factory returns an marshaler object that allows instances of the given type
-// to marshal themselves.
+// to marshal themselves. Otherwise types that implement encoding.TextMarshaler
+// (with a value or pointer receiver) are marshaled with their MarshalText
+// method.
 var defaultMarshalerFactory = newMarshalerFactory()
 
 // defaultMarshalPresence is used by the NewMarshaler function when its
@@ -249,5 +392,24 @@ func prepareMarshalOptions(opts MarshalOptions) *MarshalOptions {
 	if opts.DefaultMarshalPresence == MPUnspecified {
 		opts.DefaultMarshalPresence = defaultMarshalPresence
 	}
+	if opts.ArrayFormat == AFUnspecified {
+		opts.ArrayFormat = defaultArrayFormat
+	}
+	if opts.SpaceEscape == SEUnspecified {
+		opts.SpaceEscape = SpaceAsPlus
+	}
+	if opts.TimeLayout == "" {
+		opts.TimeLayout = defaultTimeLayout
+	}
+	if opts.DefaultStyle == StyleUnspecified {
+		opts.DefaultStyle = defaultStyle
+	}
+	if opts.KeySyntax == KSUnspecified {
+		if opts.DefaultStyle == DeepObjectStyle {
+			opts.KeySyntax = BracketSyntax
+		} else {
+			opts.KeySyntax = defaultKeySyntax
+		}
+	}
 	return &opts
 }
@@ -1,10 +1,12 @@
 package qs
 
 import (
+	"encoding"
 	"fmt"
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,7 +17,10 @@ type ptrMarshaler struct {
 
 func newPtrMarshaler(t reflect.Type, opts *MarshalOptions) (Marshaler, error) {
 	if t.Kind() != reflect.Ptr {
-		return nil, &wrongKindError{Expected: reflect.Ptr, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Ptr, Actual: t}
+	}
+	if isRecursiveElemType(t) {
+		return nil, &UnhandledTypeError{Type: t}
 	}
 	et := t.Elem()
 	em, err := opts.MarshalerFactory.Marshaler(et, opts)
@@ -31,7 +36,7 @@ func newPtrMarshaler(t reflect.Type, opts *MarshalOptions) (Marshaler, error) {
 func (p *ptrMarshaler) Marshal(v reflect.Value, opts *MarshalOptions) ([]string, error) {
 	t := v.Type()
 	if t != p.Type {
-		return nil, &wrongTypeError{Actual: t, Expected: p.Type}
+		return nil, &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 	if v.IsNil() {
 		return nil, nil
@@ -47,13 +52,21 @@ type arrayAndSliceMarshaler struct {
 func newArrayAndSliceMarshaler(t reflect.Type, opts *MarshalOptions) (Marshaler, error) {
 	k := t.Kind()
 	if k != reflect.Array && k != reflect.Slice {
-		return nil, &wrongKindError{Expected: reflect.Array, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Array, Actual: t}
+	}
+	if isRecursiveElemType(t) {
+		return nil, &UnhandledTypeError{Type: t}
 	}
 
 	em, err := opts.MarshalerFactory.Marshaler(t.Elem(), opts)
 	if err != nil {
 		return nil, err
 	}
+	if isMultiValueMarshaler(em) {
+		// The items of nested arrays and slices have to be marshaled with
+		// explicit indices by an indexedMarshaler.
+		return nil, &UnhandledTypeError{Type: t}
+	}
 	return &arrayAndSliceMarshaler{
 		Type:          t,
 		ElemMarshaler: em,
@@ -63,7 +76,7 @@ func newArrayAndSliceMarshaler(t reflect.Type, opts *MarshalOptions) (Marshaler,
 func (p *arrayAndSliceMarshaler) Marshal(v reflect.Value, opts *MarshalOptions) ([]string, error) {
 	t := v.Type()
 	if t != p.Type {
-		return nil, &wrongTypeError{Actual: t, Expected: p.Type}
+		return nil, &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 
 	vlen := v.Len()
@@ -75,26 +88,96 @@ func (p *arrayAndSliceMarshaler) Marshal(v reflect.Value, opts *MarshalOptions)
 	for i := 0; i < vlen; i++ {
 		a2, err := p.ElemMarshaler.Marshal(v.Index(i), opts)
 		if err != nil {
-			return nil, fmt.Errorf("error marshaling array/slice index %v :: %v", i, err)
+			return nil, fmt.Errorf("error marshaling array/slice index %v :: %w", i, err)
 		}
 		if len(a2) != 1 {
 			return nil, fmt.Errorf("marshaler returned a slice of length %v for array/slice index %v", len(a2), i)
 		}
 		a[i] = a2[0]
 	}
+	if sep, ok := opts.DefaultStyle.delimiter(opts.DefaultExplode); ok {
+		return []string{strings.Join(a, sep)}, nil
+	}
 	return a, nil
 }
 
+// isMultiValueMarshaler returns true if m is the builtin array and slice
+// marshaler that marshals a value into multiple items of a []string, or a
+// builtin pointer or Optional marshaler that wraps one.
+func isMultiValueMarshaler(m Marshaler) bool {
+	switch m := m.(type) {
+	case *arrayAndSliceMarshaler:
+		return true
+	case *ptrMarshaler:
+		return isMultiValueMarshaler(m.ElemMarshaler)
+	case *optionalMarshaler:
+		return isMultiValueMarshaler(m.ValueMarshaler)
+	default:
+		return false
+	}
+}
+
+// optionalPkgPath is the package path of the instances of Optional[T].
+var optionalPkgPath = reflect.TypeOf(Optional[int]{}).PkgPath()
+
+// The indices of the fields of Optional[T].
+const (
+	optionalValueField = iota
+	optionalPresentField
+	optionalEmptyField
+)
+
+// isOptionalType returns true if t is an instance of Optional[T]. The default
+// marshaler and unmarshaler factories handle such types with optionalMarshaler
+// and optionalUnmarshaler that look up the marshaler of T only once instead of
+// calling the MarshalQS and UnmarshalQS methods of Optional[T]. The type is
+// identified by its package and name because the structs that embed an
+// Optional[T] inherit its methods but not its field layout.
+func isOptionalType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.PkgPath() == optionalPkgPath && strings.HasPrefix(t.Name(), "Optional[")
+}
+
+// optionalMarshaler implements the Marshaler interface for Optional[T].
+type optionalMarshaler struct {
+	Type           reflect.Type
+	ValueMarshaler Marshaler
+}
+
+func newOptionalMarshaler(t reflect.Type, opts *MarshalOptions) (Marshaler, error) {
+	m, err := opts.MarshalerFactory.Marshaler(t.Field(optionalValueField).Type, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &optionalMarshaler{
+		Type:           t,
+		ValueMarshaler: m,
+	}, nil
+}
+
+func (p *optionalMarshaler) Marshal(v reflect.Value, opts *MarshalOptions) ([]string, error) {
+	t := v.Type()
+	if t != p.Type {
+		return nil, &WrongTypeError{Actual: t, Expected: p.Type}
+	}
+	if !v.Field(optionalPresentField).Bool() {
+		return nil, nil
+	}
+	if v.Field(optionalEmptyField).Bool() {
+		return []string{""}, nil
+	}
+	return p.ValueMarshaler.Marshal(v.Field(optionalValueField), opts)
+}
+
 func marshalString(v reflect.Value, opts *MarshalOptions) (string, error) {
 	if v.Kind() != reflect.String {
-		return "", &wrongKindError{Expected: reflect.String, Actual: v.Type()}
+		return "", &WrongKindError{Expected: reflect.String, Actual: v.Type()}
 	}
 	return v.String(), nil
 }
 
 func marshalBool(v reflect.Value, opts *MarshalOptions) (string, error) {
 	if v.Kind() != reflect.Bool {
-		return "", &wrongKindError{Expected: reflect.Bool, Actual: v.Type()}
+		return "", &WrongKindError{Expected: reflect.Bool, Actual: v.Type()}
 	}
 	return strconv.FormatBool(v.Bool()), nil
 }
@@ -104,7 +187,7 @@ func marshalInt(v reflect.Value, opts *MarshalOptions) (string, error) {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return strconv.FormatInt(v.Int(), 10), nil
 	default:
-		return "", &wrongKindError{Expected: reflect.Int, Actual: v.Type()}
+		return "", &WrongKindError{Expected: reflect.Int, Actual: v.Type()}
 	}
 }
 
@@ -113,7 +196,7 @@ func marshalUint(v reflect.Value, opts *MarshalOptions) (string, error) {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return strconv.FormatUint(v.Uint(), 10), nil
 	default:
-		return "", &wrongKindError{Expected: reflect.Uint, Actual: v.Type()}
+		return "", &WrongKindError{Expected: reflect.Uint, Actual: v.Type()}
 	}
 }
 
@@ -126,7 +209,7 @@ func marshalFloat(v reflect.Value, opts *MarshalOptions) (string, error) {
 	case reflect.Float64:
 		bitSize = 64
 	default:
-		return "", &wrongKindError{Expected: reflect.Float32, Actual: v.Type()}
+		return "", &WrongKindError{Expected: reflect.Float32, Actual: v.Type()}
 	}
 
 	return strconv.FormatFloat(v.Float(), 'f', -1, bitSize), nil
@@ -135,15 +218,26 @@ func marshalFloat(v reflect.Value, opts *MarshalOptions) (string, error) {
 func marshalTime(v reflect.Value, opts *MarshalOptions) (string, error) {
 	t := v.Type()
 	if t != timeType {
-		return "", &wrongTypeError{Actual: t, Expected: timeType}
+		return "", &WrongTypeError{Actual: t, Expected: timeType}
 	}
-	return v.Interface().(time.Time).Format(time.RFC3339), nil
+	return formatTime(v.Interface().(time.Time), opts.TimeLayout), nil
+}
+
+func marshalDuration(v reflect.Value, opts *MarshalOptions) (string, error) {
+	t := v.Type()
+	if t != durationType {
+		return "", &WrongTypeError{Actual: t, Expected: durationType}
+	}
+	if opts.DurationString {
+		return time.Duration(v.Int()).String(), nil
+	}
+	return strconv.FormatInt(v.Int(), 10), nil
 }
 
 func marshalURL(v reflect.Value, opts *MarshalOptions) (string, error) {
 	t := v.Type()
 	if t != urlType {
-		return "", &wrongTypeError{Actual: t, Expected: urlType}
+		return "", &WrongTypeError{Actual: t, Expected: urlType}
 	}
 	u := v.Interface().(url.URL)
 	return u.String(), nil
@@ -156,3 +250,24 @@ func marshalWithMarshalQS(v reflect.Value, opts *MarshalOptions) ([]string, erro
 	}
 	return marshalQS.MarshalQS(opts)
 }
+
+func marshalWithMarshalText(v reflect.Value, opts *MarshalOptions) (string, error) {
+	if !v.Type().Implements(textMarshalerInterfaceType) {
+		// MarshalText has a pointer receiver.
+		if !v.CanAddr() {
+			c := reflect.New(v.Type()).Elem()
+			c.Set(v)
+			v = c
+		}
+		v = v.Addr()
+	}
+	textMarshaler, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		return "", fmt.Errorf("expected a type that implements encoding.TextMarshaler, got %v", v.Type())
+	}
+	b, err := textMarshaler.MarshalText()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -2,11 +2,14 @@ package qs
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -894,3 +897,761 @@ func TestCheckMarshalType(t *testing.T) {
 		t.Error("unexpected success")
 	}
 }
+
+type MNestedInner struct {
+	Status string
+	Owner  string `qs:",omitempty"`
+}
+
+type MNested struct {
+	Filter    MNestedInner
+	FilterPtr *MNestedInner `qs:"filter_ptr"`
+	NilPtr    *MNestedInner
+	Outer     struct {
+		Inner MNestedInner
+	}
+}
+
+func TestMarshalNestedStruct(t *testing.T) {
+	vs, err := MarshalValues(&MNested{
+		Filter:    MNestedInner{Status: "open", Owner: "me"},
+		FilterPtr: &MNestedInner{Status: "closed"},
+		Outer: struct {
+			Inner MNestedInner
+		}{
+			Inner: MNestedInner{Status: "any"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"filter.status":      {"open"},
+		"filter.owner":       {"me"},
+		"filter_ptr.status":  {"closed"},
+		"outer.inner.status": {"any"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+}
+
+type MRecursive struct {
+	Name   string
+	Parent *MRecursive
+}
+
+type MRecursiveA struct {
+	B MRecursiveB
+}
+
+type MRecursiveB struct {
+	C []MRecursiveC
+}
+
+type MRecursiveC struct {
+	A map[string]*MRecursiveA
+}
+
+type MRecursiveEmbedded struct {
+	Name string
+	*MRecursiveEmbeddedInner
+}
+
+type MRecursiveEmbeddedInner struct {
+	*MRecursiveEmbedded
+}
+
+type MRecursiveSlice []MRecursiveSlice
+
+func TestCheckMarshalRecursiveNestedType(t *testing.T) {
+	for _, v := range []interface{}{
+		&MRecursive{},
+		&MRecursiveA{},
+		&MRecursiveEmbedded{},
+		&struct{ S MRecursiveSlice }{},
+	} {
+		err := CheckMarshal(v)
+		if err == nil {
+			t.Errorf("%T: unexpected success", v)
+			continue
+		}
+		if !strings.Contains(err.Error(), "recursive") {
+			t.Errorf("%T: expected a different error :: %v", v, err)
+		}
+		err = CheckUnmarshal(v)
+		if err == nil || !strings.Contains(err.Error(), "recursive") {
+			t.Errorf("%T: expected a recursive type error :: %v", v, err)
+		}
+	}
+}
+
+func TestMarshalNestedStructBracketSyntax(t *testing.T) {
+	marshaler := NewMarshaler(&MarshalOptions{
+		KeySyntax: BracketSyntax,
+	})
+	queryStr, err := marshaler.Marshal(&MNested{
+		Filter: MNestedInner{Status: "open"},
+		Outer: struct {
+			Inner MNestedInner
+		}{
+			Inner: MNestedInner{Status: "any"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "filter%5Bstatus%5D=open&outer%5Binner%5D%5Bstatus%5D=any"
+	if queryStr != want {
+		t.Errorf("got %q, want %q", queryStr, want)
+	}
+}
+
+type MLineItem struct {
+	Name string
+	Qty  int `qs:",omitempty"`
+}
+
+type MIndexed struct {
+	Items    []MLineItem
+	ItemPtrs []*MLineItem `qs:"item_ptrs"`
+	Pairs    [2]MLineItem
+	Matrix   [][]int
+}
+
+func TestMarshalIndexedSlices(t *testing.T) {
+	v := &MIndexed{
+		Items:    []MLineItem{{Name: "a", Qty: 2}, {Name: "b"}},
+		ItemPtrs: []*MLineItem{nil, {Name: "c"}},
+		Pairs:    [2]MLineItem{{Name: "d"}, {Name: "e"}},
+		Matrix:   [][]int{{1, 2}, {}, {3}},
+	}
+
+	vs, err := MarshalValues(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"items[0].name":     {"a"},
+		"items[0].qty":      {"2"},
+		"items[1].name":     {"b"},
+		"item_ptrs[1].name": {"c"},
+		"pairs[0].name":     {"d"},
+		"pairs[1].name":     {"e"},
+		"matrix[0]":         {"1", "2"},
+		"matrix[2]":         {"3"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+
+	marshaler := NewMarshaler(&MarshalOptions{
+		KeySyntax: BracketSyntax,
+	})
+	vs, err = marshaler.MarshalValues(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = url.Values{
+		"items[0][name]":     {"a"},
+		"items[0][qty]":      {"2"},
+		"items[1][name]":     {"b"},
+		"item_ptrs[1][name]": {"c"},
+		"pairs[0][name]":     {"d"},
+		"pairs[1][name]":     {"e"},
+		"matrix[0]":          {"1", "2"},
+		"matrix[2]":          {"3"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+}
+
+type MMapFields struct {
+	Filters map[string]string
+	Ranges  map[string][]int
+	Items   map[string]MLineItem
+}
+
+func TestMarshalMapFields(t *testing.T) {
+	v := &MMapFields{
+		Filters: map[string]string{"status": "open", "owner": "me"},
+		Ranges:  map[string][]int{"size": {1, 10}},
+		Items:   map[string]MLineItem{"x": {Name: "a", Qty: 2}},
+	}
+
+	marshaler := NewMarshaler(&MarshalOptions{
+		KeySyntax: BracketSyntax,
+	})
+	vs, err := marshaler.MarshalValues(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"filters[status]": {"open"},
+		"filters[owner]":  {"me"},
+		"ranges[size]":    {"1", "10"},
+		"items[x][name]":  {"a"},
+		"items[x][qty]":   {"2"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+
+	vs, err = MarshalValues(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = url.Values{
+		"filters.status": {"open"},
+		"filters.owner":  {"me"},
+		"ranges.size":    {"1", "10"},
+		"items.x.name":   {"a"},
+		"items.x.qty":    {"2"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+
+	vs, err = MarshalValues(&MMapFields{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{}); err != nil {
+		t.Error(err)
+	}
+}
+
+type MStyles struct {
+	Default   []int
+	Form      []int             `qs:"form,explode=false"`
+	Space     []int             `qs:"space,style=spaceDelimited"`
+	Pipe      []int             `qs:"pipe,style=pipeDelimited"`
+	PipeArr   [2]int            `qs:"pipe_arr,style=pipeDelimited"`
+	Exploded  []int             `qs:"exploded,style=pipeDelimited,explode=true"`
+	Filter    MNestedInner      `qs:"filter,style=deepObject"`
+	FilterMap map[string]string `qs:"filter_map,style=deepObject"`
+}
+
+func TestMarshalStyles(t *testing.T) {
+	vs, err := MarshalValues(&MStyles{
+		Default:   []int{1, 2},
+		Form:      []int{1, 2},
+		Space:     []int{1, 2},
+		Pipe:      []int{1, 2},
+		PipeArr:   [2]int{3, 4},
+		Exploded:  []int{1, 2},
+		Filter:    MNestedInner{Status: "open"},
+		FilterMap: map[string]string{"owner": "me"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"default":           {"1", "2"},
+		"form":              {"1,2"},
+		"space":             {"1 2"},
+		"pipe":              {"1|2"},
+		"pipe_arr":          {"3|4"},
+		"exploded":          {"1", "2"},
+		"filter[status]":    {"open"},
+		"filter_map[owner]": {"me"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshalStylesPrecomputedFieldOptions(t *testing.T) {
+	opts := NewDefaultMarshalOptions()
+	vm, err := opts.ValuesMarshalerFactory.ValuesMarshaler(reflect.TypeOf(MStyles{}), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fm := range vm.(*structMarshaler).Fields {
+		if fopts := fm.options(opts); fopts != fm.Opts {
+			t.Errorf("field %q: the options aren't precomputed", fm.Tag.Name)
+		}
+		if fopts := fm.options(opts); fopts.DefaultStyle != opts.fieldOptions(fm.Tag).DefaultStyle {
+			t.Errorf("field %q: got style %v, want %v", fm.Tag.Name, fopts.DefaultStyle, opts.fieldOptions(fm.Tag).DefaultStyle)
+		}
+	}
+}
+
+func TestMarshalDefaultStyle(t *testing.T) {
+	marshaler := NewMarshaler(&MarshalOptions{
+		DefaultStyle:   FormStyle,
+		DefaultExplode: NoExplode,
+	})
+	queryStr, err := marshaler.Marshal(&struct {
+		Ids    []int
+		Single []int `qs:"single,explode=true"`
+	}{
+		Ids:    []int{1, 2, 3},
+		Single: []int{4, 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ids=1%2C2%2C3&single=4&single=5"
+	if queryStr != want {
+		t.Errorf("got %q, want %q", queryStr, want)
+	}
+}
+
+func TestCheckMarshalInvalidStyle(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			A int `qs:",style=pipeDelimited"`
+		}{},
+		&struct {
+			A []int `qs:",style=deepObject"`
+		}{},
+		&struct {
+			A []int `qs:",style=matrix"`
+		}{},
+		&struct {
+			A []int `qs:",explode=maybe"`
+		}{},
+	} {
+		if err := CheckMarshal(v); err == nil {
+			t.Errorf("unexpected success - type: %T", v)
+		}
+	}
+}
+
+type MRemain struct {
+	Page  int
+	Extra url.Values `qs:",remain"`
+}
+
+func TestMarshalRemain(t *testing.T) {
+	vs, err := MarshalValues(&MRemain{
+		Page: 2,
+		Extra: url.Values{
+			"page":       {"5"},
+			"utm_source": {"mail"},
+			"x":          {"1", "2"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"page":       {"2"},
+		"utm_source": {"mail"},
+		"x":          {"1", "2"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckMarshalInvalidRemain(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			Extra map[string]string `qs:",remain"`
+		}{},
+		&struct {
+			Extra1 url.Values `qs:",remain"`
+			Extra2 url.Values `qs:",remain"`
+		}{},
+	} {
+		if err := CheckMarshal(v); err == nil {
+			t.Errorf("unexpected success - type: %T", v)
+		}
+	}
+}
+
+func TestMarshalOmitDefault(t *testing.T) {
+	type s struct {
+		PageSize int     `qs:",omitdefault,default=50"`
+		Sort     string  `qs:",omitdefault,default=name"`
+		Page     int     `qs:",default=1"`
+		Limit    int     `qs:",omitdefault,default=+50"`
+		Ratio    float64 `qs:",omitdefault,default=1.0"`
+		Offset   int     `qs:",omitdefault,default=0x10"`
+	}
+
+	vs, err := MarshalValues(&s{PageSize: 50, Sort: "date", Page: 1, Limit: 50, Ratio: 1, Offset: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"sort": {"date"},
+		"page": {"1"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+
+	type noDefault struct {
+		Query string `qs:",omitdefault"`
+	}
+	if err := CheckMarshal(&noDefault{}); err == nil || !strings.Contains(err.Error(), "requires a default option") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// MHooks implements the BeforeMarshalQS interface.
+type MHooks struct {
+	Sort  string
+	Order string
+}
+
+func (p *MHooks) BeforeMarshalQS(opts *MarshalOptions) error {
+	if p.Sort == "" {
+		return errors.New("missing sort")
+	}
+	if p.Order == "" {
+		p.Order = "asc"
+	}
+	return nil
+}
+
+func TestMarshalBeforeMarshalQS(t *testing.T) {
+	type s struct {
+		Top    MHooks
+		Nested []MHooks
+	}
+
+	vs, err := MarshalValues(s{
+		Top:    MHooks{Sort: "name"},
+		Nested: []MHooks{{Sort: "date", Order: "desc"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"top.sort":        {"name"},
+		"top.order":       {"asc"},
+		"nested[0].sort":  {"date"},
+		"nested[0].order": {"desc"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+
+	h := &MHooks{Sort: "name"}
+	vs, err = MarshalValues(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{"sort": {"name"}, "order": {"asc"}}); err != nil {
+		t.Error(err)
+	}
+	if h.Order != "" {
+		t.Errorf("the marshaled value has been modified: Order == %q", h.Order)
+	}
+
+	_, err = MarshalValues(&s{})
+	var he *HookError
+	if !errors.As(err, &he) || he.Method != "BeforeMarshalQS" {
+		t.Errorf("expected a HookError :: %v", err)
+	}
+}
+
+func TestMarshalBeforeMarshalQSConcurrent(t *testing.T) {
+	h := &MHooks{Sort: "name"}
+	expected := url.Values{"sort": {"name"}, "order": {"asc"}}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vs, err := MarshalValues(h)
+			if err == nil {
+				err = expectValues(vs, expected)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// MTextLevel implements the encoding.TextMarshaler interface with a pointer
+// receiver.
+type MTextLevel int
+
+func (p *MTextLevel) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("level-%d", int(*p))), nil
+}
+
+// MTextQS implements both the MarshalQS and encoding.TextMarshaler
+// interfaces. MarshalQS takes priority.
+type MTextQS string
+
+func (v MTextQS) MarshalQS(opts *MarshalOptions) ([]string, error) {
+	return []string{"qs-" + string(v)}, nil
+}
+
+func (v MTextQS) MarshalText() ([]byte, error) {
+	return []byte("text-" + string(v)), nil
+}
+
+func TestMarshalTextMarshaler(t *testing.T) {
+	type s struct {
+		IP     net.IP
+		Level  MTextLevel
+		PLevel *MTextLevel `qs:",omitempty"`
+		Levels []MTextLevel
+		Map    map[string]MTextLevel
+		QS     MTextQS
+	}
+
+	vs, err := MarshalValues(s{
+		IP:     net.IPv4(127, 0, 0, 1),
+		Level:  1,
+		Levels: []MTextLevel{2, 3},
+		Map:    map[string]MTextLevel{"a": 4},
+		QS:     "x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"ip":     {"127.0.0.1"},
+		"level":  {"level-1"},
+		"levels": {"level-2", "level-3"},
+		"map.a":  {"level-4"},
+		"qs":     {"qs-x"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshalTimeLayouts(t *testing.T) {
+	type s struct {
+		Default time.Time
+		Date    time.Time           `qs:",layout=2006-01-02"`
+		Unix    time.Time           `qs:",unix"`
+		Milli   *time.Time          `qs:",unixmilli"`
+		Dates   []time.Time         `qs:",layout=01/02"`
+		Since   Optional[time.Time] `qs:",layout=2006-01-02"`
+		Timeout time.Duration
+	}
+
+	tm := time.Date(2020, 5, 17, 10, 30, 0, 250*int(time.Millisecond), time.UTC)
+	vs, err := MarshalValues(&s{
+		Default: tm,
+		Date:    tm,
+		Unix:    tm,
+		Milli:   &tm,
+		Dates:   []time.Time{tm},
+		Since:   Some(tm),
+		Timeout: 1500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"default": {"2020-05-17T10:30:00Z"},
+		"date":    {"2020-05-17"},
+		"unix":    {"1589711400"},
+		"milli":   {"1589711400250"},
+		"dates":   {"05/17"},
+		"since":   {"2020-05-17"},
+		"timeout": {"1500000000"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+
+	m := NewMarshaler(&MarshalOptions{DurationString: true})
+	vs, err = m.MarshalValues(&struct{ D time.Duration }{1500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{"d": {"1.5s"}}); err != nil {
+		t.Error(err)
+	}
+
+	m = NewMarshaler(&MarshalOptions{TimeLayout: UnixTimeLayout})
+	vs, err = m.MarshalValues(&struct{ T time.Time }{tm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{"t": {"1589711400"}}); err != nil {
+		t.Error(err)
+	}
+
+	// The year 1 is outside the range of UnixNano.
+	m = NewMarshaler(&MarshalOptions{TimeLayout: UnixMilliTimeLayout})
+	vs, err = m.MarshalValues(&struct{ T time.Time }{time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{"t": {"-62135596800000"}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckMarshalInvalidTimeLayout(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			A string `qs:",unix"`
+		}{},
+		&struct {
+			A time.Time `qs:",unix,unixmilli"`
+		}{},
+		&struct {
+			A time.Time `qs:",layout="`
+		}{},
+	} {
+		if err := CheckMarshal(v); err == nil {
+			t.Errorf("unexpected success - type: %T", v)
+		}
+	}
+}
+
+func TestMarshalMapKeyTypes(t *testing.T) {
+	type key string
+	type s struct {
+		Named  map[key]int
+		Ints   map[int8]string
+		Uints  map[uint]string
+		Levels map[MTextLevel]string
+	}
+
+	vs, err := MarshalValues(&s{
+		Named:  map[key]int{"a": 1},
+		Ints:   map[int8]string{-1: "x"},
+		Uints:  map[uint]string{2: "y"},
+		Levels: map[MTextLevel]string{3: "z"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"named.a":        {"1"},
+		"ints.-1":        {"x"},
+		"uints.2":        {"y"},
+		"levels.level-3": {"z"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+
+	if err := CheckMarshal(map[float64]string{}); err == nil {
+		t.Error("unexpected success with float map keys")
+	}
+}
+
+type MShadowInner struct {
+	Name   string
+	Page   int
+	Filter struct{ Status string }
+}
+
+type MShadow struct {
+	MShadowInner
+	Name   string
+	Filter struct{ Owner string }
+}
+
+func TestMarshalShadowedEmbeddedFields(t *testing.T) {
+	v := &MShadow{
+		MShadowInner: MShadowInner{Name: "inner", Page: 2},
+		Name:         "outer",
+	}
+	v.MShadowInner.Filter.Status = "open"
+	v.Filter.Owner = "me"
+
+	vs, err := MarshalValues(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"name":         {"outer"},
+		"page":         {"2"},
+		"filter.owner": {"me"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+
+	b, err := AppendMarshal(nil, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "name=outer&filter.owner=me&page=2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMarshalShadowedEmbeddedFieldsBracketSyntax(t *testing.T) {
+	type inner struct {
+		Name  string
+		First string `qs:"name.first"`
+	}
+	type outer struct {
+		inner
+		Name string
+	}
+
+	m := NewMarshaler(&MarshalOptions{KeySyntax: BracketSyntax})
+	vs, err := m.MarshalValues(&outer{inner: inner{Name: "inner", First: "a"}, Name: "outer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The dot isn't a separator of BracketSyntax so "name.first" doesn't
+	// belong to the shadowed "name" key.
+	expected := url.Values{
+		"name":       {"outer"},
+		"name.first": {"a"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+}
+
+type MAmbiguous1 struct {
+	Name string
+}
+
+type MAmbiguous2 struct {
+	Name string
+}
+
+func TestCheckMarshalDuplicateKeys(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			UserID string
+			UserId string
+		}{},
+		&struct {
+			A string `qs:"x"`
+			B string `qs:"x"`
+		}{},
+		&struct {
+			MAmbiguous1
+			MAmbiguous2
+		}{},
+		&struct {
+			MAmbiguous1
+			*MAmbiguous2
+		}{},
+	} {
+		err := CheckMarshal(v)
+		if err == nil || !strings.Contains(err.Error(), "have the same key") {
+			t.Errorf("unexpected error: %v - type: %T", err, v)
+		}
+	}
+
+	// The outer field resolves the ambiguity of the embedded fields.
+	if err := CheckMarshal(&struct {
+		MAmbiguous1
+		MAmbiguous2
+		Name string
+	}{}); err != nil {
+		t.Error(err)
+	}
+}
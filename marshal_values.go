@@ -4,41 +4,136 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 )
 
-// structMarshaler implements ValuesMarshaler.
+// structMarshaler implements ValuesMarshaler. It is also used by
+// MarshalHeader with the header tag key.
 type structMarshaler struct {
-	Type           reflect.Type
+	Type reflect.Type
+	// TagKey is the struct tag key of the fields: tagKey or headerTagKey.
+	TagKey         string
 	EmbeddedFields []embeddedFieldMarshaler
 	Fields         []*fieldMarshaler
+	// RemainField is the field with the remain tag option whose entries are
+	// merged into the output. It is nil if the struct doesn't have such a
+	// field.
+	RemainField *fieldMarshaler
+	// TracksKeys is true if the struct or one of its embedded or nested
+	// values has a field with the remain tag option.
+	TracksKeys bool
+	// BeforeMarshal is true if the struct implements BeforeMarshalQS.
+	BeforeMarshal bool
+	// Keys holds the keys of the visible fields including the fields of
+	// the embedded structs.
+	Keys []fieldKey
 }
 
 type embeddedFieldMarshaler struct {
 	FieldIndex      int
 	ValuesMarshaler ValuesMarshaler
+	// ShadowedKeys holds the keys of the embedded struct that are shadowed
+	// by the fields of the outer struct. The marshaler drops them.
+	ShadowedKeys map[string]bool
 }
 
 type fieldMarshaler struct {
 	FieldIndex int
 	Marshaler  Marshaler
-	Tag        parsedTag
+	// ValuesMarshaler is used instead of Marshaler in case of nested fields
+	// (e.g.: non-embedded structs) that are marshaled into several keys
+	// prefixed with the name of the field.
+	ValuesMarshaler ValuesMarshaler
+	Tag             parsedTag
+	// Opts holds the options of the field precomputed from BaseOpts which
+	// are the options the struct marshaler was created with.
+	Opts     *MarshalOptions
+	BaseOpts *MarshalOptions
+	// Default holds the value of the default option parsed by the unmarshaler
+	// of the field type and DefaultValues holds its marshaled form with the
+	// Opts of the field. The OmitDefault presence compares the marshaled
+	// field values with DefaultValues: the default option itself may be
+	// written differently (e.g.: "050" or "1.0"). Default is invalid if the
+	// default option can't be parsed and then DefaultValues holds the raw
+	// default option.
+	Default       reflect.Value
+	DefaultValues []string
+}
+
+// options returns the options of the field derived from opts. It avoids
+// copying the options on each call unless opts differs from the options the
+// struct marshaler was created with (e.g.: nested structs in fields whose tag
+// overrides the style).
+func (p *fieldMarshaler) options(opts *MarshalOptions) *MarshalOptions {
+	if opts == p.BaseOpts {
+		return p.Opts
+	}
+	return opts.fieldOptions(p.Tag)
+}
+
+// defaultValues returns the marshaled default value of the field with the
+// given field options.
+func (p *fieldMarshaler) defaultValues(fopts *MarshalOptions) ([]string, error) {
+	if fopts == p.Opts || !p.Default.IsValid() {
+		return p.DefaultValues, nil
+	}
+	return p.Marshaler.Marshal(p.Default, fopts)
+}
+
+// setDefault parses the default option of the field and marshals it into
+// DefaultValues for the OmitDefault presence. The parsing uses the unmarshal
+// options that correspond to the marshal options of the field.
+func (p *fieldMarshaler) setDefault(t reflect.Type) error {
+	p.DefaultValues = []string{p.Tag.Default}
+	uopts := prepareUnmarshalOptions(UnmarshalOptions{
+		KeySyntax:      p.Opts.KeySyntax,
+		DefaultStyle:   p.Opts.DefaultStyle,
+		DefaultExplode: p.Opts.DefaultExplode,
+		ArrayFormat:    p.Opts.ArrayFormat,
+		TimeLayout:     p.Opts.TimeLayout,
+	})
+	um, err := uopts.UnmarshalerFactory.Unmarshaler(t, uopts)
+	if err != nil {
+		// Types without unmarshaler fall back to comparing the raw default.
+		return nil
+	}
+	dv := reflect.New(t).Elem()
+	if err := um.Unmarshal(dv, []string{p.Tag.Default}, uopts); err != nil {
+		return fmt.Errorf("invalid default value %q :: %w", p.Tag.Default, err)
+	}
+	a, err := p.Marshaler.Marshal(dv, p.Opts)
+	if err != nil {
+		return fmt.Errorf("error marshaling default value %q :: %w", p.Tag.Default, err)
+	}
+	p.Default, p.DefaultValues = dv, a
+	return nil
 }
 
 // newStructMarshaler creates a struct marshaler for a specific struct type.
 func newStructMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshaler, error) {
+	return newTaggedStructMarshaler(t, tagKey, opts)
+}
+
+// newTaggedStructMarshaler creates a struct marshaler that reads the names and
+// options of the fields from the struct tags with the given key.
+func newTaggedStructMarshaler(t reflect.Type, key string, opts *MarshalOptions) (*structMarshaler, error) {
 	if t.Kind() != reflect.Struct {
-		return nil, &wrongKindError{Expected: reflect.Struct, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Struct, Actual: t}
 	}
 
 	sm := &structMarshaler{
-		Type: t,
+		Type:          t,
+		TagKey:        key,
+		BeforeMarshal: implementsHook(t, beforeMarshalQSInterfaceType),
 	}
 
 	for i, numField := 0, t.NumField(); i < numField; i++ {
 		sf := t.Field(i)
-		vm, fm, err := newFieldMarshaler(sf, opts)
+		vm, fm, err := newFieldMarshaler(sf, key, opts)
 		if err != nil {
-			return nil, fmt.Errorf("error creating marshaler for field %v of struct %v :: %v",
+			return nil, fmt.Errorf("error creating marshaler for field %v of struct %v :: %w",
 				sf.Name, t, err)
 		}
 		if vm != nil {
@@ -46,78 +141,229 @@ func newStructMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshaler,
 				FieldIndex:      i,
 				ValuesMarshaler: vm,
 			})
+			sm.TracksKeys = sm.TracksKeys || tracksKeys(vm)
 		}
 		if fm != nil {
 			fm.FieldIndex = i
+			fm.BaseOpts, fm.Opts = opts, opts.fieldOptions(fm.Tag)
+			if fm.Marshaler != nil && fm.Tag.MarshalPresence == OmitDefault && fm.Tag.HasDefault {
+				if err := fm.setDefault(sf.Type); err != nil {
+					return nil, fmt.Errorf("error creating marshaler for field %v of struct %v :: %w",
+						sf.Name, t, err)
+				}
+			}
+			if fm.Tag.Remain {
+				if sm.RemainField != nil {
+					return nil, fmt.Errorf("struct %v has more than one field with the remain option", t)
+				}
+				sm.RemainField = fm
+				sm.TracksKeys = true
+				continue
+			}
+			if fm.ValuesMarshaler != nil {
+				sm.TracksKeys = sm.TracksKeys || tracksKeys(fm.ValuesMarshaler)
+			}
 			sm.Fields = append(sm.Fields, fm)
 		}
 	}
 
+	own := make([]fieldKey, len(sm.Fields))
+	for i, fm := range sm.Fields {
+		own[i] = fieldKey{Key: fm.Tag.Name, Field: t.Field(fm.FieldIndex).Name}
+	}
+	embedded := make([][]fieldKey, len(sm.EmbeddedFields))
+	for i, ef := range sm.EmbeddedFields {
+		embedded[i] = embeddedFieldKeys(t.Field(ef.FieldIndex).Name, ef.ValuesMarshaler)
+	}
+	keys, shadowed, err := resolveFieldKeys(t, own, embedded)
+	if err != nil {
+		return nil, err
+	}
+	sm.Keys = keys
+	for i := range sm.EmbeddedFields {
+		sm.EmbeddedFields[i].ShadowedKeys = shadowed[i]
+	}
+
 	return sm, nil
 }
 
-func newFieldMarshaler(sf reflect.StructField, opts *MarshalOptions) (vm ValuesMarshaler, fm *fieldMarshaler, err error) {
-	skip, tag, err := getStructFieldInfo(sf, opts.NameTransformer, opts.DefaultMarshalPresence, UPUnspecified)
+func newFieldMarshaler(sf reflect.StructField, key string, opts *MarshalOptions) (vm ValuesMarshaler, fm *fieldMarshaler, err error) {
+	skip, tag, err := getStructFieldInfo(sf, key, opts.NameTransformer, opts.DefaultMarshalPresence, UPUnspecified)
 	if skip || err != nil {
 		return
 	}
+	if err = checkFieldStyle(sf.Type, tag); err != nil {
+		return
+	}
+	if err = checkTimeLayout(sf.Type, tag); err != nil {
+		return
+	}
+	if tag.Remain {
+		if err = checkRemainField(sf, tag); err == nil {
+			fm = &fieldMarshaler{Tag: tag}
+		}
+		return
+	}
 
 	t := sf.Type
 	if sf.Anonymous {
-		vm, err = opts.ValuesMarshalerFactory.ValuesMarshaler(t, opts)
-		if err == nil {
-			// We can end up here for example in case of an embedded struct.
+		if isRecursiveType(t, key) {
+			err = fmt.Errorf("recursive embedded type: %v", t)
 			return
 		}
+		if key == headerTagKey {
+			vm, skip, err = newEmbeddedHeaderMarshaler(sf, opts)
+			if vm != nil || skip || err != nil {
+				return
+			}
+		} else {
+			vm, err = opts.ValuesMarshalerFactory.ValuesMarshaler(t, opts)
+			if err == nil {
+				// We can end up here for example in case of an embedded struct.
+				return
+			}
+		}
 	}
 
 	m, err := opts.MarshalerFactory.Marshaler(t, opts)
+	if err == nil {
+		fm = &fieldMarshaler{
+			Marshaler: m,
+			Tag:       tag,
+		}
+		return
+	}
+	// Headers are flat: they can't hold nested values.
+	if key == headerTagKey || !isNestedType(t) {
+		return
+	}
+
+	if isRecursiveType(t, key) {
+		err = fmt.Errorf("recursive nested type: %v", t)
+		return
+	}
+	nvm, err := newNestedMarshaler(t, opts)
 	if err != nil {
 		return
 	}
 	fm = &fieldMarshaler{
-		Marshaler: m,
-		Tag:       tag,
+		ValuesMarshaler: nvm,
+		Tag:             tag,
 	}
 	return
 }
 
+// newNestedMarshaler creates a ValuesMarshaler for a nested type.
+// See isNestedType.
+func newNestedMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshaler, error) {
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice:
+		return newIndexedMarshaler(t, opts)
+	default:
+		return opts.ValuesMarshalerFactory.ValuesMarshaler(t, opts)
+	}
+}
+
 func (p *structMarshaler) MarshalValues(v reflect.Value, opts *MarshalOptions) (url.Values, error) {
+	vs := make(url.Values, len(p.Fields))
+	if err := p.marshalTo(valuesMapSink(vs), v, opts); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+func (p *structMarshaler) marshalTo(s valuesSink, v reflect.Value, opts *MarshalOptions) error {
 	t := v.Type()
 	if t != p.Type {
-		return nil, &wrongTypeError{Actual: t, Expected: p.Type}
+		return &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 
 	// TODO: use a StructError error type in the function to generate
 	// error messages prefixed with the name of the struct type.
 
-	vs := make(url.Values, len(p.Fields))
+	if p.BeforeMarshal {
+		var err error
+		if v, err = callBeforeMarshal(v, opts); err != nil {
+			return err
+		}
+	}
 
 	for _, fm := range p.Fields {
 		fv := v.Field(fm.FieldIndex)
 		if fm.Tag.MarshalPresence == OmitEmpty && isEmpty(fv) {
 			continue
 		}
-		a, err := fm.Marshaler.Marshal(fv, opts)
+		if fm.Tag.MarshalPresence == OmitDefault && !fm.Tag.HasDefault && isEmpty(fv) {
+			continue
+		}
+		fopts := fm.options(opts)
+		if fm.ValuesMarshaler != nil {
+			ns := &prefixSink{Sink: s, Prefix: fm.Tag.Name, KeySyntax: fopts.KeySyntax}
+			if err := marshalTo(fm.ValuesMarshaler, ns, fv, fopts); err != nil {
+				return fmt.Errorf("error marshaling nested field %q :: %w", fm.Tag.Name, err)
+			}
+			continue
+		}
+		a, err := fm.Marshaler.Marshal(fv, fopts)
 		if err != nil {
-			return nil, fmt.Errorf("error marshaling url.Values entry %q :: %v", fm.Tag.Name, err)
+			return fmt.Errorf("error marshaling %v %q :: %w", p.entryName(), fm.Tag.Name, err)
+		}
+		if fm.Tag.MarshalPresence == OmitDefault && fm.Tag.HasDefault {
+			d, err := fm.defaultValues(fopts)
+			if err != nil {
+				return fmt.Errorf("error marshaling the default value of %v %q :: %w", p.entryName(), fm.Tag.Name, err)
+			}
+			if slices.Equal(a, d) {
+				continue
+			}
 		}
 		if len(a) != 0 {
-			vs[fm.Tag.Name] = a
+			switch {
+			case !isMultiValueMarshaler(fm.Marshaler):
+				s.add(fm.Tag.Name, a)
+			case p.TagKey == headerTagKey:
+				s.add(fm.Tag.Name, []string{joinHeaderList(a)})
+			default:
+				fopts.ArrayFormat.setValues(s, fm.Tag.Name, a)
+			}
 		}
 	}
 
 	for _, ef := range p.EmbeddedFields {
-		evs, err := ef.ValuesMarshaler.MarshalValues(v.Field(ef.FieldIndex), opts)
-		if err != nil {
-			return nil, fmt.Errorf("error marshaling embedded field %q :: %v", v.Type().Field(ef.FieldIndex).Name, err)
+		es := s
+		if ef.ShadowedKeys != nil {
+			es = &shadowSink{Sink: s, Shadowed: ef.ShadowedKeys, KeySyntax: opts.KeySyntax}
 		}
-		for k, a := range evs {
-			vs[k] = a
+		if err := marshalTo(ef.ValuesMarshaler, es, v.Field(ef.FieldIndex), opts); err != nil {
+			return fmt.Errorf("error marshaling embedded field %q :: %w", v.Type().Field(ef.FieldIndex).Name, err)
 		}
 	}
 
-	return vs, nil
+	if p.RemainField != nil {
+		// The keys of the other fields take precedence over the remaining
+		// keys.
+		rv := v.Field(p.RemainField.FieldIndex)
+		keys := make([]string, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !s.has(k) {
+				s.add(k, rv.MapIndex(reflect.ValueOf(k)).Interface().([]string))
+			}
+		}
+	}
+
+	return nil
+}
+
+// entryName returns the name of the marshaled entries used in error messages.
+func (p *structMarshaler) entryName() string {
+	if p.TagKey == headerTagKey {
+		return "header"
+	}
+	return "url.Values entry"
 }
 
 func isEmpty(v reflect.Value) bool {
@@ -142,56 +388,99 @@ func isEmpty(v reflect.Value) bool {
 type mapMarshaler struct {
 	Type          reflect.Type
 	ElemMarshaler Marshaler
+	// ElemValuesMarshaler is used instead of ElemMarshaler when the map
+	// values are nested values (e.g.: structs) that are marshaled into
+	// several keys prefixed with the map key.
+	ElemValuesMarshaler ValuesMarshaler
 }
 
 func newMapMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshaler, error) {
 	if t.Kind() != reflect.Map {
-		return nil, &wrongKindError{Expected: reflect.Map, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Map, Actual: t}
 	}
 
-	if t.Key() != stringType {
-		return nil, fmt.Errorf("map key type is expected to be string: %v", t)
+	if err := checkMapKeyType(t, true); err != nil {
+		return nil, err
 	}
 
 	et := t.Elem()
 	m, err := opts.MarshalerFactory.Marshaler(et, opts)
-	if err != nil {
-		// TODO: use a MapError error type in the function to generate
-		// error messages prefixed with the name of the struct type.
-		return nil, fmt.Errorf("error getting marshaler for map value type %v :: %v", et, err)
+	if err == nil {
+		return &mapMarshaler{
+			Type:          t,
+			ElemMarshaler: m,
+		}, nil
 	}
-
-	return &mapMarshaler{
-		Type:          t,
-		ElemMarshaler: m,
-	}, nil
+	if isNestedType(et) && !isRecursiveType(et, tagKey) {
+		var vm ValuesMarshaler
+		vm, err = newNestedMarshaler(et, opts)
+		if err == nil {
+			return &mapMarshaler{
+				Type:                t,
+				ElemValuesMarshaler: vm,
+			}, nil
+		}
+	}
+	// TODO: use a MapError error type in the function to generate
+	// error messages prefixed with the name of the struct type.
+	return nil, fmt.Errorf("error getting marshaler for map value type %v :: %w", et, err)
 }
 
 func (p *mapMarshaler) MarshalValues(v reflect.Value, opts *MarshalOptions) (url.Values, error) {
 	t := v.Type()
 	if t != p.Type {
-		return nil, &wrongTypeError{Actual: t, Expected: p.Type}
+		return nil, &WrongTypeError{Actual: t, Expected: p.Type}
 	}
-
-	vlen := v.Len()
-	if vlen == 0 {
+	if v.Len() == 0 {
 		return nil, nil
 	}
+	vs := make(url.Values, v.Len())
+	if err := p.marshalTo(valuesMapSink(vs), v, opts); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
 
-	vs := make(url.Values, vlen)
+// marshalTo marshals the map entries in the order of their marshaled keys.
+func (p *mapMarshaler) marshalTo(s valuesSink, v reflect.Value, opts *MarshalOptions) error {
+	t := v.Type()
+	if t != p.Type {
+		return &WrongTypeError{Actual: t, Expected: p.Type}
+	}
+
+	type entry struct {
+		key string
+		val reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
 	for _, key := range v.MapKeys() {
 		val := v.MapIndex(key)
-		if opts.DefaultMarshalPresence == OmitEmpty && isEmpty(val) {
+		if (opts.DefaultMarshalPresence == OmitEmpty || opts.DefaultMarshalPresence == OmitDefault) && isEmpty(val) {
 			continue
 		}
-		keyStr := key.String()
-		a, err := p.ElemMarshaler.Marshal(val, opts)
+		keyStr, err := marshalMapKey(key)
 		if err != nil {
-			return nil, fmt.Errorf("error marshaling key %q :: %v", keyStr, err)
+			return err
 		}
-		vs[keyStr] = a
+		entries = append(entries, entry{keyStr, val})
 	}
-	return vs, nil
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	for _, e := range entries {
+		if p.ElemValuesMarshaler != nil {
+			es := &prefixSink{Sink: s, Prefix: e.key, KeySyntax: opts.KeySyntax}
+			if err := marshalTo(p.ElemValuesMarshaler, es, e.val, opts); err != nil {
+				return fmt.Errorf("error marshaling key %q :: %w", e.key, err)
+			}
+			continue
+		}
+		a, err := p.ElemMarshaler.Marshal(e.val, opts)
+		if err != nil {
+			return fmt.Errorf("error marshaling key %q :: %w", e.key, err)
+		}
+		s.add(e.key, a)
+	}
+	return nil
 }
 
 type ptrValuesMarshaler struct {
@@ -201,7 +490,10 @@ type ptrValuesMarshaler struct {
 
 func newPtrValuesMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshaler, error) {
 	if t.Kind() != reflect.Ptr {
-		return nil, &wrongKindError{Expected: reflect.Ptr, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Ptr, Actual: t}
+	}
+	if isRecursiveElemType(t) {
+		return nil, &UnhandledTypeError{Type: t}
 	}
 	et := t.Elem()
 	em, err := opts.ValuesMarshalerFactory.ValuesMarshaler(et, opts)
@@ -217,10 +509,117 @@ func newPtrValuesMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshale
 func (p *ptrValuesMarshaler) MarshalValues(v reflect.Value, opts *MarshalOptions) (url.Values, error) {
 	t := v.Type()
 	if t != p.Type {
-		return nil, &wrongTypeError{Actual: t, Expected: p.Type}
+		return nil, &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 	if v.IsNil() {
 		return nil, nil
 	}
 	return p.ElemMarshaler.MarshalValues(v.Elem(), opts)
 }
+
+func (p *ptrValuesMarshaler) marshalTo(s valuesSink, v reflect.Value, opts *MarshalOptions) error {
+	t := v.Type()
+	if t != p.Type {
+		return &WrongTypeError{Actual: t, Expected: p.Type}
+	}
+	if v.IsNil() {
+		return nil
+	}
+	return marshalTo(p.ElemMarshaler, s, v.Elem(), opts)
+}
+
+// indexedMarshaler implements ValuesMarshaler. It marshals the items of arrays
+// and slices with explicit indices in their keys. E.g.: "[0].name=a&[1].name=b"
+// that becomes "items[0].name=a&items[1].name=b" when it is nested into the
+// Items field of a struct.
+type indexedMarshaler struct {
+	Type                reflect.Type
+	ElemMarshaler       Marshaler
+	ElemValuesMarshaler ValuesMarshaler
+}
+
+func newIndexedMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshaler, error) {
+	k := t.Kind()
+	if k != reflect.Array && k != reflect.Slice {
+		return nil, &WrongKindError{Expected: reflect.Slice, Actual: t}
+	}
+
+	et := t.Elem()
+	em, err := opts.MarshalerFactory.Marshaler(et, opts)
+	if err == nil {
+		return &indexedMarshaler{
+			Type:          t,
+			ElemMarshaler: em,
+		}, nil
+	}
+	if !isNestedType(et) {
+		return nil, err
+	}
+
+	evm, err := newNestedMarshaler(et, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &indexedMarshaler{
+		Type:                t,
+		ElemValuesMarshaler: evm,
+	}, nil
+}
+
+func (p *indexedMarshaler) MarshalValues(v reflect.Value, opts *MarshalOptions) (url.Values, error) {
+	t := v.Type()
+	if t != p.Type {
+		return nil, &WrongTypeError{Actual: t, Expected: p.Type}
+	}
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	vs := make(url.Values, v.Len())
+	if err := p.marshalTo(valuesMapSink(vs), v, opts); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+func (p *indexedMarshaler) marshalTo(s valuesSink, v reflect.Value, opts *MarshalOptions) error {
+	t := v.Type()
+	if t != p.Type {
+		return &WrongTypeError{Actual: t, Expected: p.Type}
+	}
+
+	for i, vlen := 0, v.Len(); i < vlen; i++ {
+		index := "[" + strconv.Itoa(i) + "]"
+		if p.ElemMarshaler != nil {
+			a, err := p.ElemMarshaler.Marshal(v.Index(i), opts)
+			if err != nil {
+				return fmt.Errorf("error marshaling array/slice index %v :: %w", i, err)
+			}
+			if len(a) != 0 {
+				s.add(index, a)
+			}
+			continue
+		}
+
+		es := &prefixSink{Sink: s, Prefix: index, KeySyntax: opts.KeySyntax}
+		if err := marshalTo(p.ElemValuesMarshaler, es, v.Index(i), opts); err != nil {
+			return fmt.Errorf("error marshaling array/slice index %v :: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (p *structMarshaler) tracksKeys() bool {
+	return p.TracksKeys
+}
+
+func (p *ptrValuesMarshaler) tracksKeys() bool {
+	return tracksKeys(p.ElemMarshaler)
+}
+
+func (p *mapMarshaler) tracksKeys() bool {
+	return p.ElemValuesMarshaler != nil && tracksKeys(p.ElemValuesMarshaler)
+}
+
+func (p *indexedMarshaler) tracksKeys() bool {
+	return p.ElemValuesMarshaler != nil && tracksKeys(p.ElemValuesMarshaler)
+}
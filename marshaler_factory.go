@@ -1,6 +1,7 @@
 package qs
 
 import (
+	"encoding"
 	"net/url"
 	"reflect"
 )
@@ -55,8 +56,9 @@ func newValuesMarshalerFactory() ValuesMarshalerFactory {
 func newMarshalerFactory() MarshalerFactory {
 	return &marshalerFactory{
 		Types: map[reflect.Type]Marshaler{
-			timeType: primitiveMarshalerFunc(marshalTime),
-			urlType:  primitiveMarshalerFunc(marshalURL),
+			timeType:     primitiveMarshalerFunc(marshalTime),
+			durationType: primitiveMarshalerFunc(marshalDuration),
+			urlType:      primitiveMarshalerFunc(marshalURL),
 		},
 		KindSubRegistries: map[reflect.Kind]MarshalerFactory{
 			reflect.Ptr:   marshalerFactoryFunc(newPtrMarshaler),
@@ -91,11 +93,14 @@ type valuesMarshalerFactory struct {
 }
 
 func (p *valuesMarshalerFactory) ValuesMarshaler(t reflect.Type, opts *MarshalOptions) (ValuesMarshaler, error) {
+	if hasGeneratedMethod(t, marshalQSValuesInterfaceType) {
+		return newGeneratedMarshaler(t, opts)
+	}
 	if subFactory, ok := p.KindSubRegistries[t.Kind()]; ok {
 		return subFactory.ValuesMarshaler(t, opts)
 	}
 
-	return nil, &unhandledTypeError{Type: t}
+	return nil, &UnhandledTypeError{Type: t}
 }
 
 // marshalerFactory implements the MarshalerFactory interface.
@@ -106,15 +111,23 @@ type marshalerFactory struct {
 }
 
 var marshalQSInterfaceType = reflect.TypeOf((*MarshalQS)(nil)).Elem()
+var textMarshalerInterfaceType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 
 func (p *marshalerFactory) Marshaler(t reflect.Type, opts *MarshalOptions) (Marshaler, error) {
 	if marshaler, ok := p.Types[t]; ok {
 		return marshaler, nil
 	}
 
+	if isOptionalType(t) {
+		return newOptionalMarshaler(t, opts)
+	}
 	if t.Implements(marshalQSInterfaceType) {
 		return marshalerFunc(marshalWithMarshalQS), nil
 	}
+	// Pointers are left to the ptrMarshaler to handle nil values.
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(textMarshalerInterfaceType) {
+		return primitiveMarshalerFunc(marshalWithMarshalText), nil
+	}
 
 	k := t.Kind()
 	if subFactory, ok := p.KindSubRegistries[k]; ok {
@@ -124,7 +137,7 @@ func (p *marshalerFactory) Marshaler(t reflect.Type, opts *MarshalOptions) (Mars
 		return marshaler, nil
 	}
 
-	return nil, &unhandledTypeError{Type: t}
+	return nil, &UnhandledTypeError{Type: t}
 }
 
 // valuesMarshalerFactoryFunc implements the ValuesMarshalerFactory interface.
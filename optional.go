@@ -0,0 +1,81 @@
+package qs
+
+import "reflect"
+
+// Optional is a struct field type that records whether its key was missing
+// from the query string, present with an empty value (e.g.: "name=") or
+// present with a value. Pointer fields with the nil option can't tell apart
+// the first two cases.
+//
+// The MarshalerFactory and UnmarshalerFactory of the options handle its Value
+// so T can be any type supported by the factories (e.g.: int, time.Time or
+// []string). The default factories look up the marshaler and unmarshaler of
+// T once per Optional type. Optional also implements the MarshalQS and
+// UnmarshalQS interfaces for custom factories.
+//
+// The default tag option sets the Value of a missing field but Present
+// remains false just like the FieldSet returned by UnmarshalValuesPresence
+// reports defaulted fields as not present.
+type Optional[T any] struct {
+	// Value holds the unmarshaled value or the value of the default tag
+	// option. It is the zero value of T if the key is missing and the field
+	// has no default or if Empty is true.
+	Value T
+
+	// Present is true if the key of the field was in the query string.
+	Present bool
+
+	// Empty is true if the key of the field was in the query string without
+	// a value.
+	Empty bool
+}
+
+// Some returns an Optional that is present with the given value.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Present: true}
+}
+
+// MarshalQS marshals nothing if the Optional isn't present and an empty
+// string if it is Empty.
+func (o Optional[T]) MarshalQS(opts *MarshalOptions) ([]string, error) {
+	if !o.Present {
+		return nil, nil
+	}
+	if o.Empty {
+		return []string{""}, nil
+	}
+	m, err := opts.MarshalerFactory.Marshaler(o.valueType(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return m.Marshal(reflect.ValueOf(&o.Value).Elem(), opts)
+}
+
+// UnmarshalQS sets Present if a isn't nil and sets Empty if a contains only
+// empty strings.
+func (o *Optional[T]) UnmarshalQS(a []string, opts *UnmarshalOptions) error {
+	*o = Optional[T]{}
+	if a == nil {
+		return nil
+	}
+	o.Present = true
+	o.Empty = true
+	for _, s := range a {
+		if s != "" {
+			o.Empty = false
+			break
+		}
+	}
+	if o.Empty {
+		return nil
+	}
+	um, err := opts.UnmarshalerFactory.Unmarshaler(o.valueType(), opts)
+	if err != nil {
+		return err
+	}
+	return um.Unmarshal(reflect.ValueOf(&o.Value).Elem(), a, opts)
+}
+
+func (o *Optional[T]) valueType() reflect.Type {
+	return reflect.TypeOf(&o.Value).Elem()
+}
@@ -0,0 +1,153 @@
+package qs
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+type optionalQuery struct {
+	Name  Optional[string]
+	Page  Optional[int]
+	Since Optional[time.Time]
+	Ids   Optional[[]int]
+}
+
+func TestUnmarshalOptional(t *testing.T) {
+	var q optionalQuery
+	err := Unmarshal(&q, "name=&page=2&since=2020-05-17T10:30:00Z&ids=1&ids=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Name.Present", q.Name.Present, true)
+	cr.compare("Name.Empty", q.Name.Empty, true)
+	cr.compare("Page.Present", q.Page.Present, true)
+	cr.compare("Page.Empty", q.Page.Empty, false)
+	cr.compare("Page.Value", q.Page.Value, 2)
+	cr.compare("Since.Value", q.Since.Value.Equal(time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)), true)
+	cr.compare("len(Ids.Value)", len(q.Ids.Value), 2)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	q = optionalQuery{Page: Some(5)}
+	if err := Unmarshal(&q, "name=x"); err != nil {
+		t.Fatal(err)
+	}
+	cr = &comparisonResults{}
+	cr.compare("Name.Value", q.Name.Value, "x")
+	cr.compare("Page.Present", q.Page.Present, false)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	if err := Unmarshal(&q, "page=x"); err == nil {
+		t.Error("unexpected success")
+	}
+}
+
+func TestMarshalOptional(t *testing.T) {
+	vs, err := MarshalValues(&optionalQuery{
+		Name: Optional[string]{Present: true, Empty: true},
+		Page: Some(2),
+		Ids:  Some([]int{1, 2}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"name": {""},
+		"page": {"2"},
+		"ids":  {"1", "2"},
+	}
+	if err := expectValues(vs, expected); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalOptionalDefault(t *testing.T) {
+	var q struct {
+		Page  Optional[int]    `qs:",default=10"`
+		Empty Optional[string] `qs:",default="`
+	}
+	fs, err := UnmarshalValuesPresence(&q, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Page.Value", q.Page.Value, 10)
+	cr.compare("Page.Present", q.Page.Present, false)
+	cr.compare("Page.Empty", q.Page.Empty, false)
+	cr.compare("Empty.Present", q.Empty.Present, false)
+	cr.compare("Empty.Empty", q.Empty.Empty, false)
+	cr.compare("Has(Page)", fs.Has("Page"), false)
+
+	fs, err = UnmarshalValuesPresence(&q, url.Values{"page": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr.compare("page=2 Page.Value", q.Page.Value, 2)
+	cr.compare("page=2 Page.Present", q.Page.Present, true)
+	cr.compare("page=2 Has(Page)", fs.Has("Page"), true)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOptionalArrayFormat(t *testing.T) {
+	type query struct {
+		Ids Optional[[]int]
+	}
+	opts := &MarshalOptions{ArrayFormat: ArrayIndices}
+	vs, err := NewMarshaler(opts).MarshalValues(&query{Ids: Some([]int{1, 2})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{"ids[0]": {"1"}, "ids[1]": {"2"}}); err != nil {
+		t.Error(err)
+	}
+
+	var q query
+	um := NewUnmarshaler(&UnmarshalOptions{ArrayFormat: ArrayIndices})
+	if err := um.UnmarshalValues(&q, vs); err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Ids.Present", q.Ids.Present, true)
+	cr.compare("Ids.Value", q.Ids.Value, []int{1, 2})
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+// optionalNote embeds an Optional so it inherits the MarshalQS and UnmarshalQS
+// methods of Optional but it isn't an Optional itself.
+type optionalNote struct {
+	Optional[int]
+	Note string
+}
+
+func TestEmbeddedOptional(t *testing.T) {
+	type query struct {
+		Count optionalNote
+	}
+	vs, err := MarshalValues(&query{Count: optionalNote{Optional: Some(5), Note: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expectValues(vs, url.Values{"count": {"5"}}); err != nil {
+		t.Error(err)
+	}
+
+	var q query
+	if err := Unmarshal(&q, "count=7"); err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Count.Present", q.Count.Present, true)
+	cr.compare("Count.Value", q.Count.Value, 7)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
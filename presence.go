@@ -0,0 +1,105 @@
+package qs
+
+import (
+	"net/url"
+	"sort"
+)
+
+// FieldSet holds the struct fields that were present in the url.Values
+// unmarshaled by UnmarshalValuesPresence. It maps the Go paths of the fields
+// (e.g.: "Filter.Status") to their keys in the query string
+// (e.g.: "filter.status").
+//
+// A field is present if the query string contains its key even if the value
+// is empty (e.g.: "status="). Fields set from the default option of their tag
+// aren't present. Nested structs are present if the query string contains at
+// least one of their keys. The paths of the fields of embedded structs contain
+// the name of the embedded struct just like the Field of FieldError.
+type FieldSet map[string]string
+
+// Has returns true if the field with the given Go path was present.
+func (s FieldSet) Has(field string) bool {
+	_, ok := s[field]
+	return ok
+}
+
+// HasKey returns true if the field with the given query string key was
+// present.
+func (s FieldSet) HasKey(key string) bool {
+	for _, k := range s {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Fields returns the Go paths of the present fields in sorted order.
+func (s FieldSet) Fields() []string {
+	fields := make([]string, 0, len(s))
+	for f := range s {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// presenceCollector is implemented by the builtin ValuesUnmarshalers that can
+// tell which struct fields are present in the unmarshaled url.Values.
+type presenceCollector interface {
+	// collectPresence adds the present fields to fs. The field and key
+	// parameters are the Go path and the query string key of the value
+	// that is unmarshaled from vs.
+	collectPresence(fs FieldSet, field, key string, vs url.Values, opts *UnmarshalOptions)
+}
+
+func collectPresence(vum ValuesUnmarshaler, fs FieldSet, field, key string, vs url.Values, opts *UnmarshalOptions) {
+	if pc, ok := vum.(presenceCollector); ok {
+		pc.collectPresence(fs, field, key, vs, opts)
+	}
+}
+
+func (p *structUnmarshaler) collectPresence(fs FieldSet, field, key string, vs url.Values, opts *UnmarshalOptions) {
+	joinField := func(name string) string {
+		if field == "" {
+			return name
+		}
+		return field + "." + name
+	}
+	joinKey := func(name string) string {
+		if key == "" {
+			return name
+		}
+		return opts.KeySyntax.join(key, name)
+	}
+
+	for _, fum := range p.Fields {
+		fopts := fum.options(opts)
+		fpath := joinField(p.Type.Field(fum.FieldIndex).Name)
+		fkey := joinKey(fum.Tag.Name)
+		if fum.ValuesUnmarshaler != nil {
+			nvs := subValues(vs, fum.Tag.Name, fopts.KeySyntax)
+			if len(nvs) != 0 {
+				fs[fpath] = fkey
+				collectPresence(fum.ValuesUnmarshaler, fs, fpath, fkey, nvs, fopts)
+			}
+			continue
+		}
+		_, ok := vs[fum.Tag.Name]
+		if !ok && isMultiValueUnmarshaler(fum.Unmarshaler) {
+			_, ok, _ = fopts.ArrayFormat.values(vs, fum.Tag.Name, fopts.sliceIndexLimit())
+		}
+		if ok {
+			fs[fpath] = fkey
+		}
+	}
+
+	for _, ef := range p.EmbeddedFields {
+		evs := withoutShadowedKeys(vs, ef.ShadowedKeys, opts.KeySyntax)
+		collectPresence(ef.ValuesUnmarshaler, fs, joinField(p.Type.Field(ef.FieldIndex).Name), key, evs, opts)
+	}
+}
+
+func (p *ptrValuesUnmarshaler) collectPresence(fs FieldSet, field, key string, vs url.Values, opts *UnmarshalOptions) {
+	collectPresence(p.ElemUnmarshaler, fs, field, key, vs, opts)
+}
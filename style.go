@@ -0,0 +1,344 @@
+package qs
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Style is an enum that controls the serialization of arrays, slices and
+// nested values. The values of this enum correspond to the parameter
+// serialization styles of OpenAPI 3 query parameters.
+type Style int
+
+const (
+	// StyleUnspecified is the zero value of Style. In most cases you will use
+	// this implicitly by simply leaving the DefaultStyle field of
+	// MarshalOptions or UnmarshalOptions uninitialised which results in using
+	// the default Style which is FormStyle.
+	StyleUnspecified Style = iota
+
+	// FormStyle marshals the items of arrays and slices by repeating the key
+	// of the field ("ids=1&ids=2") or, when it isn't exploded, as a single
+	// comma separated value ("ids=1,2").
+	FormStyle
+
+	// SpaceDelimitedStyle is the same as FormStyle except that it separates
+	// the items of non-exploded arrays and slices with spaces ("ids=1+2" or
+	// "ids=1%202" with the SpaceAsPercent20 SpaceEscape option).
+	SpaceDelimitedStyle
+
+	// PipeDelimitedStyle is the same as FormStyle except that it separates
+	// the items of non-exploded arrays and slices with pipes ("ids=1|2").
+	PipeDelimitedStyle
+
+	// DeepObjectStyle marshals the fields of nested structs and the entries
+	// of maps using BracketSyntax ("filter[status]=open") regardless of the
+	// KeySyntax of the marshaler.
+	DeepObjectStyle
+)
+
+func (v Style) String() string {
+	switch v {
+	case StyleUnspecified:
+		return "StyleUnspecified"
+	case FormStyle:
+		// using the OpenAPI names to match the format used in struct tags
+		return "form"
+	case SpaceDelimitedStyle:
+		return "spaceDelimited"
+	case PipeDelimitedStyle:
+		return "pipeDelimited"
+	case DeepObjectStyle:
+		return "deepObject"
+	default:
+		return fmt.Sprintf("Style(%v)", int(v))
+	}
+}
+
+// defaultStyle is used by the NewMarshaler and NewUnmarshaler functions when
+// the DefaultStyle field of their options is StyleUnspecified.
+const defaultStyle = FormStyle
+
+func parseStyle(s string) (Style, error) {
+	for _, style := range []Style{FormStyle, SpaceDelimitedStyle, PipeDelimitedStyle, DeepObjectStyle} {
+		if s == style.String() {
+			return style, nil
+		}
+	}
+	return StyleUnspecified, fmt.Errorf("invalid style: %q", s)
+}
+
+// ExplodeMode is an enum that controls whether the items of arrays and slices
+// are marshaled into separate values with the same key (exploded) or into
+// a single delimited value.
+type ExplodeMode int
+
+const (
+	// EMUnspecified is the zero value of ExplodeMode. In most cases you will
+	// use this implicitly by simply leaving the DefaultExplode field of
+	// MarshalOptions or UnmarshalOptions uninitialised which results in
+	// exploding only the arrays and slices that use FormStyle just like
+	// OpenAPI 3 does.
+	EMUnspecified ExplodeMode = iota
+
+	// Explode marshals the items of arrays and slices into separate values
+	// with the same key. E.g.: "ids=1&ids=2".
+	Explode
+
+	// NoExplode marshals the items of arrays and slices into a single value
+	// in which the items are separated by the delimiter of the Style.
+	// E.g.: "ids=1,2" with FormStyle.
+	NoExplode
+)
+
+func (v ExplodeMode) String() string {
+	switch v {
+	case EMUnspecified:
+		return "EMUnspecified"
+	case Explode:
+		return "Explode"
+	case NoExplode:
+		return "NoExplode"
+	default:
+		return fmt.Sprintf("ExplodeMode(%v)", int(v))
+	}
+}
+
+// delimiter returns the separator of the items of non-exploded arrays and
+// slices. It returns false if the items have to be exploded.
+func (v Style) delimiter(em ExplodeMode) (string, bool) {
+	var sep string
+	switch v {
+	case FormStyle:
+		sep = ","
+	case SpaceDelimitedStyle:
+		sep = " "
+	case PipeDelimitedStyle:
+		sep = "|"
+	default:
+		// DeepObjectStyle doesn't define a serialization for arrays so we
+		// fall back to repeating the key.
+		return "", false
+	}
+
+	switch em {
+	case Explode:
+		return "", false
+	case NoExplode:
+		return sep, true
+	default:
+		return sep, v != FormStyle
+	}
+}
+
+// checkFieldStyle returns an error if the style in the tag of a struct field
+// can't be used with the type of the field.
+func checkFieldStyle(t reflect.Type, tag parsedTag) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch tag.Style {
+	case SpaceDelimitedStyle, PipeDelimitedStyle:
+		if k := t.Kind(); k != reflect.Array && k != reflect.Slice {
+			return fmt.Errorf("style %v can be used only with arrays and slices: %v", tag.Style, t)
+		}
+	case DeepObjectStyle:
+		if k := t.Kind(); k != reflect.Struct && k != reflect.Map {
+			return fmt.Errorf("style %v can be used only with structs and maps: %v", tag.Style, t)
+		}
+	}
+	return nil
+}
+
+// fieldOptions returns the options to be used with the value of a struct
+// field. The result is p itself unless the tag of the field overrides the
+// default style, explode mode or time layout.
+func (p *MarshalOptions) fieldOptions(tag parsedTag) *MarshalOptions {
+	if tag.Style == StyleUnspecified && tag.Explode == EMUnspecified && tag.TimeLayout == "" {
+		return p
+	}
+	opts := *p
+	if tag.Style != StyleUnspecified {
+		opts.DefaultStyle = tag.Style
+		if tag.Style == DeepObjectStyle {
+			opts.KeySyntax = BracketSyntax
+		}
+	}
+	if tag.Explode != EMUnspecified {
+		opts.DefaultExplode = tag.Explode
+	}
+	if tag.TimeLayout != "" {
+		opts.TimeLayout = tag.TimeLayout
+	}
+	return &opts
+}
+
+// fieldOptions returns the options to be used with the value of a struct
+// field. The result is p itself unless the tag of the field overrides the
+// default style, explode mode or time layout.
+func (p *UnmarshalOptions) fieldOptions(tag parsedTag) *UnmarshalOptions {
+	if tag.Style == StyleUnspecified && tag.Explode == EMUnspecified && tag.TimeLayout == "" {
+		return p
+	}
+	opts := *p
+	if tag.Style != StyleUnspecified {
+		opts.DefaultStyle = tag.Style
+		if tag.Style == DeepObjectStyle {
+			opts.KeySyntax = BracketSyntax
+		}
+	}
+	if tag.Explode != EMUnspecified {
+		opts.DefaultExplode = tag.Explode
+	}
+	if tag.TimeLayout != "" {
+		opts.TimeLayout = tag.TimeLayout
+	}
+	return &opts
+}
+
+// splitDelimited splits the delimited values of a non-exploded array or
+// slice into items.
+func splitDelimited(a []string, sep string) []string {
+	var items []string
+	for _, s := range a {
+		if s == "" {
+			continue
+		}
+		items = append(items, strings.Split(s, sep)...)
+	}
+	return items
+}
+
+// ArrayFormat is an enum that controls the keys of struct fields that are
+// arrays or slices of primitive values. The values of this enum correspond to
+// the arrayFormat option of the JavaScript qs library.
+type ArrayFormat int
+
+const (
+	// AFUnspecified is the zero value of ArrayFormat. In most cases you will
+	// use this implicitly by simply leaving the ArrayFormat field of
+	// MarshalOptions or UnmarshalOptions uninitialised which results in
+	// using the default ArrayFormat which is ArrayRepeat.
+	AFUnspecified ArrayFormat = iota
+
+	// ArrayRepeat repeats the key of the field for every item.
+	// E.g.: "ids=1&ids=2".
+	ArrayRepeat
+
+	// ArrayIndices puts the index of the item after the key of the field.
+	// E.g.: "ids[0]=1&ids[1]=2".
+	ArrayIndices
+
+	// ArrayBrackets puts empty square brackets after the key of the field.
+	// E.g.: "ids[]=1&ids[]=2".
+	ArrayBrackets
+
+	// ArrayComma marshals the items into a single comma separated value.
+	// E.g.: "ids=1,2".
+	ArrayComma
+)
+
+func (v ArrayFormat) String() string {
+	switch v {
+	case AFUnspecified:
+		return "AFUnspecified"
+	case ArrayRepeat:
+		return "ArrayRepeat"
+	case ArrayIndices:
+		return "ArrayIndices"
+	case ArrayBrackets:
+		return "ArrayBrackets"
+	case ArrayComma:
+		return "ArrayComma"
+	default:
+		return fmt.Sprintf("ArrayFormat(%v)", int(v))
+	}
+}
+
+// defaultArrayFormat is used by the NewMarshaler and NewUnmarshaler functions
+// when the ArrayFormat field of their options is AFUnspecified.
+const defaultArrayFormat = ArrayRepeat
+
+// setValues adds the marshaled items of an array or slice struct field to s
+// using the keys defined by the ArrayFormat.
+func (v ArrayFormat) setValues(s valuesSink, key string, a []string) {
+	switch v {
+	case ArrayIndices:
+		for i, item := range a {
+			s.add(key+"["+strconv.Itoa(i)+"]", []string{item})
+		}
+	case ArrayBrackets:
+		s.add(key+"[]", a)
+	case ArrayComma:
+		s.add(key, []string{strings.Join(a, ",")})
+	default:
+		s.add(key, a)
+	}
+}
+
+// values collects the items of an array or slice struct field from vs. The
+// ArrayIndices and ArrayBrackets formats accept the keys of all three formats
+// (repeated, indexed and bracketed) just like the parser of the JavaScript qs
+// library. The ok return value is false if vs doesn't contain any of the keys.
+func (v ArrayFormat) values(vs url.Values, key string, maxIndex int) (a []string, ok bool, err error) {
+	a, ok = vs[key]
+	switch v {
+	case ArrayComma:
+		if ok {
+			a = splitDelimited(a, ",")
+		}
+		return
+	case ArrayIndices, ArrayBrackets:
+	default:
+		return
+	}
+
+	// Copying the slice to avoid modifying the url.Values of the caller.
+	a = append([]string(nil), a...)
+	if b, found := vs[key+"[]"]; found {
+		a = append(a, b...)
+		ok = true
+	}
+
+	prefix := key + "["
+	indexed := make(map[int][]string)
+	var indices []int
+	for k, b := range vs {
+		if len(k) <= len(prefix)+1 || !strings.HasPrefix(k, prefix) || !strings.HasSuffix(k, "]") {
+			continue
+		}
+		s := k[len(prefix) : len(k)-1]
+		if strings.ContainsAny(s, "[]") {
+			continue
+		}
+		i, err := parseIndex(s, maxIndex)
+		if err != nil {
+			return nil, false, err
+		}
+		indexed[i] = b
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		a = append(a, indexed[i]...)
+		ok = true
+	}
+	return
+}
+
+// hasItemKey returns true if key is one of the bracketed or indexed keys that
+// the values method collects for the field with the given name.
+func (v ArrayFormat) hasItemKey(key, name string) bool {
+	if v != ArrayIndices && v != ArrayBrackets {
+		return false
+	}
+	prefix := name + "["
+	if len(key) <= len(prefix) || !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, "]") {
+		return false
+	}
+	return !strings.ContainsAny(key[len(prefix):len(key)-1], "[]")
+}
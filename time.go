@@ -0,0 +1,80 @@
+package qs
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	// UnixTimeLayout can be used as the TimeLayout of MarshalOptions and
+	// UnmarshalOptions to marshal time.Time values as the number of seconds
+	// elapsed since the Unix epoch. The unix tag option selects this layout
+	// for a single struct field.
+	UnixTimeLayout = "unix"
+
+	// UnixMilliTimeLayout can be used as the TimeLayout of MarshalOptions and
+	// UnmarshalOptions to marshal time.Time values as the number of
+	// milliseconds elapsed since the Unix epoch. The unixmilli tag option
+	// selects this layout for a single struct field.
+	UnixMilliTimeLayout = "unixmilli"
+)
+
+// defaultTimeLayout is used by the NewMarshaler and NewUnmarshaler functions
+// when the TimeLayout field of their options is empty.
+const defaultTimeLayout = time.RFC3339
+
+// checkTimeLayout returns an error if the tag of a struct field has a time
+// layout option but the field isn't a time.Time (or a pointer, array, slice
+// or Optional of time.Time).
+func checkTimeLayout(t reflect.Type, tag parsedTag) error {
+	if tag.TimeLayout == "" {
+		return nil
+	}
+	if isOptionalType(t) {
+		t = t.Field(optionalValueField).Type
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if k := t.Kind(); k == reflect.Array || k == reflect.Slice {
+		t = t.Elem()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	if t != timeType {
+		return fmt.Errorf("time layout options can be used only with time.Time fields: %v", t)
+	}
+	return nil
+}
+
+func formatTime(tm time.Time, layout string) string {
+	switch layout {
+	case UnixTimeLayout:
+		return strconv.FormatInt(tm.Unix(), 10)
+	case UnixMilliTimeLayout:
+		return strconv.FormatInt(tm.UnixMilli(), 10)
+	default:
+		return tm.Format(layout)
+	}
+}
+
+// parseTime parses s using the given layout. Values that don't specify their
+// time zone are interpreted in loc.
+func parseTime(s, layout string, loc *time.Location) (time.Time, error) {
+	switch layout {
+	case UnixTimeLayout, UnixMilliTimeLayout:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if layout == UnixTimeLayout {
+			return time.Unix(i, 0).In(loc), nil
+		}
+		return time.UnixMilli(i).In(loc), nil
+	default:
+		return time.ParseInLocation(layout, s, loc)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"strings"
+	"time"
 )
 
 // UnmarshalPresence is an enum that controls the unmarshaling of fields.
@@ -93,6 +95,89 @@ type UnmarshalOptions struct {
 	// DefaultUnmarshalPresence is used for the unmarshaling of struct fields
 	// that don't have an explicit UnmarshalPresence option set in their tags.
 	DefaultUnmarshalPresence UnmarshalPresence
+
+	// KeySyntax controls how the keys of nested struct fields are split into
+	// the name of the field and the keys of the nested value. If this field is
+	// KSUnspecified then NewUnmarshaler uses DotSyntax.
+	KeySyntax KeySyntax
+
+	// DefaultStyle is used for the unmarshaling of struct fields that don't
+	// have an explicit style option set in their tags. If this field is
+	// StyleUnspecified then NewUnmarshaler uses FormStyle.
+	DefaultStyle Style
+
+	// DefaultExplode is used for the unmarshaling of struct fields that don't
+	// have an explicit explode option set in their tags. If this field is
+	// EMUnspecified then only the arrays and slices unmarshaled with FormStyle
+	// are expected to be exploded.
+	DefaultExplode ExplodeMode
+
+	// ArrayFormat controls the keys of struct fields that are arrays or
+	// slices of primitive values. If this field is AFUnspecified then
+	// NewUnmarshaler uses ArrayRepeat. ArrayIndices and ArrayBrackets accept
+	// repeated, indexed and bracketed keys as well.
+	ArrayFormat ArrayFormat
+
+	// MaxDepth is the maximum number of nested segments in a key after the
+	// first segment. E.g.: "a[b][c]" has a depth of 2. The segments of keys
+	// nested deeper are kept as a single literal segment like in case of the
+	// parse function of the JavaScript qs library: "a[b][c][d]" with a
+	// MaxDepth of 1 is unmarshaled as the "[c][d]" key of the nested value
+	// "b" (e.g.: a map field). If this field is zero then the depth isn't
+	// limited.
+	MaxDepth int
+
+	// ParameterLimit is the maximum number of parameters parsed by the
+	// Unmarshal method of the unmarshaler. The parameters after the limit are
+	// ignored. If this field is zero then the number of parameters isn't
+	// limited.
+	ParameterLimit int
+
+	// CollectErrors makes the unmarshaler continue with the remaining fields
+	// after a field fails to unmarshal. If it is true then the unmarshaler
+	// returns a *MultiError that holds the errors of all failed fields
+	// including the missing required fields.
+	CollectErrors bool
+
+	// DisallowUnknownKeys makes the unmarshaler fail with an
+	// *UnknownKeysError if the unmarshaled url.Values contains keys that
+	// aren't consumed by any struct field (including the fields of embedded
+	// and nested structs). With CollectErrors the *UnknownKeysError is the
+	// last item of the returned *MultiError.
+	DisallowUnknownKeys bool
+
+	// AllowedUnknownKeys is a list of patterns of the keys that are accepted
+	// even if they aren't consumed by any struct field when
+	// DisallowUnknownKeys is set. The patterns use the syntax of path.Match.
+	// E.g.: "utm_*".
+	AllowedUnknownKeys []string
+
+	// MaxSliceIndex is the largest array/slice index accepted in the keys of
+	// arrays and slices whose items are unmarshaled from explicitly indexed
+	// keys (e.g.: "items[2].name=a"). Slices are extended to hold the item
+	// with the largest index so this limit protects against huge allocations.
+	// If this field is zero then NewUnmarshaler uses a default of 1000.
+	MaxSliceIndex int
+
+	// CompactSlices makes the unmarshaler ignore the gaps between the
+	// explicit indices of array and slice items: the items are stored in the
+	// order of their indices without empty items between them (e.g.:
+	// "a[1]=x&a[5]=y" is unmarshaled as []string{"x", "y"}). The indices
+	// aren't limited by MaxSliceIndex in this case because the length of the
+	// slice is limited by the number of keys.
+	CompactSlices bool
+
+	// TimeLayout is the layout used to parse time.Time values (see
+	// time.Parse). It can also be UnixTimeLayout or UnixMilliTimeLayout.
+	// The layout, unix and unixmilli tag options override it for a single
+	// field. If this field is empty then NewUnmarshaler uses time.RFC3339.
+	TimeLayout string
+
+	// TimeLocation is the location of the parsed time.Time values that don't
+	// specify their time zone (e.g.: the layout is "2006-01-02"). Unix
+	// timestamps are also converted to this location. If this field is nil
+	// then NewUnmarshaler uses time.UTC.
+	TimeLocation *time.Location
 }
 
 // DefaultUnmarshaler is the unmarshaler used by the Unmarshal, UnmarshalValues,
@@ -116,9 +201,57 @@ var DefaultUnmarshaler = NewUnmarshaler(&UnmarshalOptions{})
 //  - req causes the unmarshal operation to fail with an error that can be
 //    detected using qs.IsRequiredFieldError.
 //
+// The default=value tag option (e.g.: `qs:"page_size,default=50"`) provides
+// a value that is unmarshaled into the field when the query string doesn't
+// contain a value for it. In this case the UnmarshalPresence of the field is
+// ignored. The default value can't contain commas and it is validated when
+// the unmarshaler of the struct type is created.
+//
+// The min=number, max=number, len=length, maxlen=length, pattern=regexp and
+// oneof=a|b|c tag options declare constraints that are checked after
+// unmarshaling the value of the field from the query string (or from its
+// default value). Length constraints are checked against the length of
+// strings (in characters), arrays and slices while the other constraints are
+// checked against each item of arrays and slices. The pattern isn't anchored
+// implicitly (like the pattern keyword of OpenAPI 3) so it has to start with
+// ^ and end with $ to match the whole value. The pattern option takes the
+// rest of the tag so it has to be the last option but it can contain commas
+// (e.g.: `qs:",pattern=^[0-9]{1,3}$"`). The other options can't contain
+// commas. A violation is reported as a *FieldError that wraps a
+// *ConstraintError.
+//
 // When unmarshaling a nil pointer field that is present in the query string
 // the pointer is automatically initialised even if it has the nil option in
 // its tag.
+//
+// Nested struct fields are unmarshaled from the keys that are prefixed with
+// the name of the field (e.g.: "filter.status=open" or "filter[status]=open"
+// depending on the KeySyntax of the unmarshaler). A nested field is
+// considered to be missing from the query string if none of the keys has its
+// prefix.
+//
+// Map fields are unmarshaled from the keys that are prefixed with the name of
+// the field. E.g.: "filters[status]=open&filters[owner]=me" is unmarshaled
+// into a Filters field of type map[string]string as two map entries. Keys that
+// can't be converted into the key type of the map (see Marshal) are reported
+// as a *FieldError that wraps a *MapKeyError.
+//
+// Arrays and slices of structs, arrays or slices are unmarshaled from keys
+// that contain explicit indices (e.g.: "items[0].name=a&items[1].name=b").
+// The indices can be sparse and out of order. Slices are resized to hold the
+// item with the largest index that can't be greater than the MaxSliceIndex
+// option of the unmarshaler: the surplus items of a pre-filled slice are
+// dropped.
+//
+// The errors caused by the values of struct fields are returned as *FieldError
+// values that contain the full path of the field and wrap the cause of the
+// error so they can be inspected with errors.As and errors.Is.
+//
+// If the top-level struct or a nested struct implements the AfterUnmarshalQS
+// and/or Validate interfaces then their methods are called (in this order)
+// after unmarshaling all fields of the struct without errors. The error
+// returned by these methods is wrapped into a *HookError which is wrapped
+// into a *FieldError in case of nested structs.
 func Unmarshal(into interface{}, queryString string) error {
 	return DefaultUnmarshaler.Unmarshal(into, queryString)
 }
@@ -129,6 +262,15 @@ func UnmarshalValues(into interface{}, values url.Values) error {
 	return DefaultUnmarshaler.UnmarshalValues(into, values)
 }
 
+// UnmarshalValuesPresence is the same as UnmarshalValues but it also returns
+// the set of struct fields that were present in values. This can be used to
+// tell apart the fields that were missing from the query string and the fields
+// that were explicitly set to an empty value (e.g.: for partial updates).
+// The FieldSet is returned even if unmarshaling fails.
+func UnmarshalValuesPresence(into interface{}, values url.Values) (FieldSet, error) {
+	return DefaultUnmarshaler.UnmarshalValuesPresence(into, values)
+}
+
 // CheckUnmarshal returns an error if the type of the given object can't be
 // unmarshaled from a url.Vales or query string. By default only maps and structs
 // can be unmarshaled from query strings given that all of their fields or values
@@ -152,45 +294,117 @@ func CheckUnmarshalType(t reflect.Type) error {
 // used to unmarshal query strings or url.Values into structs or maps.
 type QSUnmarshaler struct {
 	opts *UnmarshalOptions
+	// headerCache holds the header struct unmarshalers of the types passed to
+	// UnmarshalHeader.
+	headerCache syncMap
+	// bindCache holds the request sources of the struct fields of the
+	// types passed to BindRequest.
+	bindCache syncMap
 }
 
 // NewUnmarshaler returns a new QSUnmarshaler object.
 func NewUnmarshaler(opts *UnmarshalOptions) *QSUnmarshaler {
 	return &QSUnmarshaler{
-		opts: prepareUnmarshalOptions(*opts),
+		opts:        prepareUnmarshalOptions(*opts),
+		headerCache: newSyncMap(),
+		bindCache:   newSyncMap(),
 	}
 }
 
 // Unmarshal unmarshals an object from a query string.
 // See the documentation of the global Unmarshal func.
 func (p *QSUnmarshaler) Unmarshal(into interface{}, queryString string) error {
-	values, err := url.ParseQuery(queryString)
+	values, err := p.parseQuery(queryString)
 	if err != nil {
-		return fmt.Errorf("error parsing query string %q :: %v", queryString, err)
+		return err
 	}
 	return p.UnmarshalValues(into, values)
 }
 
+// parseQuery parses the query string into a url.Values applying the
+// ParameterLimit option.
+func (p *QSUnmarshaler) parseQuery(queryString string) (url.Values, error) {
+	if p.opts.ParameterLimit > 0 {
+		parts := strings.SplitN(queryString, "&", p.opts.ParameterLimit+1)
+		if len(parts) > p.opts.ParameterLimit {
+			queryString = strings.Join(parts[:p.opts.ParameterLimit], "&")
+		}
+	}
+	values, err := url.ParseQuery(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing query string %q :: %w", queryString, err)
+	}
+	return values, nil
+}
+
 // UnmarshalValues unmarshals an object from a url.Values.
 // See the documentation of the global UnmarshalValues func.
 func (p *QSUnmarshaler) UnmarshalValues(into interface{}, values url.Values) error {
+	_, err := p.unmarshalValues(into, values, false)
+	return err
+}
+
+// UnmarshalValuesPresence unmarshals an object from a url.Values and returns
+// the set of struct fields that were present in the url.Values.
+// See the documentation of the global UnmarshalValuesPresence func.
+func (p *QSUnmarshaler) UnmarshalValuesPresence(into interface{}, values url.Values) (FieldSet, error) {
+	return p.unmarshalValues(into, values, true)
+}
+
+func (p *QSUnmarshaler) unmarshalValues(into interface{}, values url.Values, presence bool) (FieldSet, error) {
 	pv := reflect.ValueOf(into)
 	if !pv.IsValid() {
-		return errors.New("received an empty interface")
+		return nil, errors.New("received an empty interface")
 	}
 	if pv.Kind() != reflect.Ptr {
-		return fmt.Errorf("expected a pointer, got %T", into)
+		return nil, fmt.Errorf("expected a pointer, got %T", into)
 	}
 	if pv.IsNil() {
-		return fmt.Errorf("nil pointer of type %T", into)
+		return nil, fmt.Errorf("nil pointer of type %T", into)
 	}
 	v := pv.Elem()
 
 	vum, err := p.opts.ValuesUnmarshalerFactory.ValuesUnmarshaler(v.Type(), p.opts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return p.decode(vum, v, values, presence)
+}
+
+// decode unmarshals values into v using the ValuesUnmarshaler of the type
+// of v.
+func (p *QSUnmarshaler) decode(vum ValuesUnmarshaler, v reflect.Value, values url.Values, presence bool) (FieldSet, error) {
+	if p.opts.MaxDepth > 0 {
+		values = limitDepth(values, p.opts.KeySyntax, p.opts.MaxDepth)
+	}
+
+	var fs FieldSet
+	if presence {
+		fs = make(FieldSet)
+		collectPresence(vum, fs, "", "", values, p.opts)
+	}
+
+	var unknownKeysErr error
+	if p.opts.DisallowUnknownKeys {
+		unknownKeysErr = checkUnknownKeys(vum, values, p.opts)
+		if unknownKeysErr != nil && !p.opts.CollectErrors {
+			return fs, unknownKeysErr
+		}
+	}
+
+	err := vum.UnmarshalValues(v, values, p.opts)
+	if unknownKeysErr == nil {
+		return fs, err
+	}
+	switch e := err.(type) {
+	case nil:
+		return fs, &MultiError{Errors: []error{unknownKeysErr}}
+	case *MultiError:
+		e.Errors = append(e.Errors, unknownKeysErr)
+		return fs, e
+	default:
+		return fs, &MultiError{Errors: []error{err, unknownKeysErr}}
 	}
-	return vum.UnmarshalValues(v, values, p.opts)
 }
 
 // CheckUnmarshal check whether the type of the given object supports
@@ -237,9 +451,26 @@ var defaultValuesUnmarshalerFactory = newValuesUnmarshalerFactory()
 // to a factory object that handles most builtin types (arrays, pointers,
 // bool, int, etc...). If a type implements the UnmarshalQS interface then this
 // factory returns an unmarshaler object that allows instances of the given type
-// to unmarshal themselves.
+// to unmarshal themselves. Otherwise types whose pointer implements
+// encoding.TextUnmarshaler are unmarshaled with their UnmarshalText method.
 var defaultUnmarshalerFactory = newUnmarshalerFactory()
 
+// defaultMaxSliceIndex is used by the NewUnmarshaler function when its
+// UnmarshalOptions.MaxSliceIndex parameter is zero.
+const defaultMaxSliceIndex = 1000
+
+// maxInt is the largest value of the int type.
+const maxInt = int(^uint(0) >> 1)
+
+// sliceIndexLimit returns the largest array/slice index accepted in the keys
+// of the query string.
+func (p *UnmarshalOptions) sliceIndexLimit() int {
+	if p.CompactSlices {
+		return maxInt
+	}
+	return p.MaxSliceIndex
+}
+
 // defaultUnmarshalPresence is used by the NewUnmarshaler function when its
 // UnmarshalOptions.DefaultUnmarshalPresence parameter is UPUnspecified.
 const defaultUnmarshalPresence = Opt
@@ -265,5 +496,27 @@ func prepareUnmarshalOptions(opts UnmarshalOptions) *UnmarshalOptions {
 	if opts.DefaultUnmarshalPresence == UPUnspecified {
 		opts.DefaultUnmarshalPresence = defaultUnmarshalPresence
 	}
+	if opts.ArrayFormat == AFUnspecified {
+		opts.ArrayFormat = defaultArrayFormat
+	}
+	if opts.DefaultStyle == StyleUnspecified {
+		opts.DefaultStyle = defaultStyle
+	}
+	if opts.KeySyntax == KSUnspecified {
+		if opts.DefaultStyle == DeepObjectStyle {
+			opts.KeySyntax = BracketSyntax
+		} else {
+			opts.KeySyntax = defaultKeySyntax
+		}
+	}
+	if opts.MaxSliceIndex == 0 {
+		opts.MaxSliceIndex = defaultMaxSliceIndex
+	}
+	if opts.TimeLayout == "" {
+		opts.TimeLayout = defaultTimeLayout
+	}
+	if opts.TimeLocation == nil {
+		opts.TimeLocation = time.UTC
+	}
 	return &opts
 }
@@ -0,0 +1,176 @@
+package qs
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// keyClaimer is implemented by the builtin ValuesUnmarshalers that can tell
+// which keys of the url.Values they consume. It is used to find the unknown
+// keys of the unmarshaled url.Values.
+type keyClaimer interface {
+	// claimsKey returns true if the given key is consumed by the
+	// UnmarshalValues method of the ValuesUnmarshaler.
+	claimsKey(key string, opts *UnmarshalOptions) bool
+}
+
+// claimsKey returns true if the given key is consumed by vum. ValuesUnmarshalers
+// that don't implement keyClaimer (e.g.: custom ValuesUnmarshalers) are
+// expected to consume every key.
+func claimsKey(vum ValuesUnmarshaler, key string, opts *UnmarshalOptions) bool {
+	if kc, ok := vum.(keyClaimer); ok {
+		return kc.claimsKey(key, opts)
+	}
+	return true
+}
+
+func (p *structUnmarshaler) claimsKey(key string, opts *UnmarshalOptions) bool {
+	return p.RemainField != nil || p.claimsFieldKey(key, -1, opts)
+}
+
+// claimsFieldKey returns true if key is consumed by a field of the struct
+// other than the field with the remain tag option. The embedded field with
+// index skip of EmbeddedFields is ignored (-1 doesn't skip any).
+func (p *structUnmarshaler) claimsFieldKey(key string, skip int, opts *UnmarshalOptions) bool {
+	for _, fum := range p.Fields {
+		fopts := fum.options(opts)
+		if fum.ValuesUnmarshaler != nil {
+			head, rest, ok := fopts.KeySyntax.cut(key)
+			if ok && head == fum.Tag.Name && claimsKey(fum.ValuesUnmarshaler, rest, fopts) {
+				return true
+			}
+			continue
+		}
+		if key == fum.Tag.Name {
+			return true
+		}
+		if isMultiValueUnmarshaler(fum.Unmarshaler) && fopts.ArrayFormat.hasItemKey(key, fum.Tag.Name) {
+			return true
+		}
+	}
+
+	for i, ef := range p.EmbeddedFields {
+		if i == skip || isShadowedKey(key, ef.ShadowedKeys, opts.KeySyntax) {
+			continue
+		}
+		if claimsKey(ef.ValuesUnmarshaler, key, opts) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutClaimedKeys returns the entries of vs that aren't consumed by the
+// fields of the struct other than the embedded field with index skip. The
+// remain field of an embedded struct receives only these keys so it doesn't
+// capture the keys of the outer struct and its other embedded structs.
+func (p *structUnmarshaler) withoutClaimedKeys(vs url.Values, skip int, opts *UnmarshalOptions) url.Values {
+	res := make(url.Values, len(vs))
+	for k, a := range vs {
+		if !p.claimsFieldKey(k, skip, opts) {
+			res[k] = a
+		}
+	}
+	return res
+}
+
+// remainHolder is implemented by the builtin ValuesUnmarshalers of structs.
+type remainHolder interface {
+	// hasRemain returns true if the struct or one of its embedded structs
+	// has a field with the remain tag option.
+	hasRemain() bool
+}
+
+// hasRemainField returns true if vum unmarshals a struct that has a field
+// with the remain tag option (including the fields of embedded structs).
+func hasRemainField(vum ValuesUnmarshaler) bool {
+	if rh, ok := vum.(remainHolder); ok {
+		return rh.hasRemain()
+	}
+	return false
+}
+
+func (p *structUnmarshaler) hasRemain() bool {
+	return p.Remain
+}
+
+func (p *ptrValuesUnmarshaler) hasRemain() bool {
+	return hasRemainField(p.ElemUnmarshaler)
+}
+
+func (p *mapUnmarshaler) claimsKey(key string, opts *UnmarshalOptions) bool {
+	if p.ElemValuesUnmarshaler == nil {
+		return true
+	}
+	_, rest, ok := opts.KeySyntax.cut(key)
+	return ok && claimsKey(p.ElemValuesUnmarshaler, rest, opts)
+}
+
+func (p *ptrValuesUnmarshaler) claimsKey(key string, opts *UnmarshalOptions) bool {
+	return claimsKey(p.ElemUnmarshaler, key, opts)
+}
+
+func (p *indexedUnmarshaler) claimsKey(key string, opts *UnmarshalOptions) bool {
+	_, rest, nested := opts.KeySyntax.cut(key)
+	if nested != (p.ElemValuesUnmarshaler != nil) {
+		return false
+	}
+	// The UnmarshalValues method fails with an error if the index is
+	// invalid so the key is claimed by this unmarshaler anyway.
+	if !nested {
+		return true
+	}
+	return claimsKey(p.ElemValuesUnmarshaler, rest, opts)
+}
+
+// UnknownKeysError is returned by the unmarshaler when the
+// DisallowUnknownKeys option is set and the query string contains keys that
+// aren't consumed by any of the struct fields.
+type UnknownKeysError struct {
+	// Keys holds the unknown keys in sorted order.
+	Keys []string
+}
+
+func (e *UnknownKeysError) Error() string {
+	return fmt.Sprintf("unknown keys in query string: %v", strings.Join(e.Keys, ", "))
+}
+
+// checkUnknownKeys returns an *UnknownKeysError if vs contains keys that
+// aren't consumed by vum and don't match any of the AllowedUnknownKeys
+// patterns.
+func checkUnknownKeys(vum ValuesUnmarshaler, vs url.Values, opts *UnmarshalOptions) error {
+	var unknown []string
+	for k := range vs {
+		if claimsKey(vum, k, opts) {
+			continue
+		}
+		allowed, err := isAllowedUnknownKey(k, opts.AllowedUnknownKeys)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return &UnknownKeysError{Keys: unknown}
+}
+
+func isAllowedUnknownKey(key string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			return false, fmt.Errorf("invalid AllowedUnknownKeys pattern %q :: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -1,6 +1,7 @@
 package qs
 
 import (
+	"encoding"
 	"fmt"
 	"net/url"
 	"reflect"
@@ -16,7 +17,10 @@ type ptrUnmarshaler struct {
 
 func newPtrUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (Unmarshaler, error) {
 	if t.Kind() != reflect.Ptr {
-		return nil, &wrongKindError{Expected: reflect.Ptr, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Ptr, Actual: t}
+	}
+	if isRecursiveElemType(t) {
+		return nil, &UnhandledTypeError{Type: t}
 	}
 	et := t.Elem()
 	eu, err := opts.UnmarshalerFactory.Unmarshaler(et, opts)
@@ -33,7 +37,7 @@ func newPtrUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (Unmarshaler, err
 func (p *ptrUnmarshaler) Unmarshal(v reflect.Value, a []string, opts *UnmarshalOptions) error {
 	t := v.Type()
 	if t != p.Type {
-		return &wrongTypeError{Actual: t, Expected: p.Type}
+		return &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 	if v.IsNil() {
 		v.Set(reflect.New(p.ElemType))
@@ -49,13 +53,21 @@ type arrayUnmarshaler struct {
 
 func newArrayUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (Unmarshaler, error) {
 	if t.Kind() != reflect.Array {
-		return nil, &wrongKindError{Expected: reflect.Array, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Array, Actual: t}
+	}
+	if isRecursiveElemType(t) {
+		return nil, &UnhandledTypeError{Type: t}
 	}
 
 	eu, err := opts.UnmarshalerFactory.Unmarshaler(t.Elem(), opts)
 	if err != nil {
 		return nil, err
 	}
+	if isMultiValueUnmarshaler(eu) {
+		// The items of nested arrays and slices have to be unmarshaled from
+		// explicit indices by an indexedUnmarshaler.
+		return nil, &UnhandledTypeError{Type: t}
+	}
 	return &arrayUnmarshaler{
 		Type:            t,
 		ElemUnmarshaler: eu,
@@ -66,19 +78,22 @@ func newArrayUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (Unmarshaler, e
 func (p *arrayUnmarshaler) Unmarshal(v reflect.Value, a []string, opts *UnmarshalOptions) error {
 	t := v.Type()
 	if t != p.Type {
-		return &wrongTypeError{Actual: t, Expected: p.Type}
+		return &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 
 	if a == nil {
 		return nil
 	}
+	if sep, ok := opts.DefaultStyle.delimiter(opts.DefaultExplode); ok {
+		a = splitDelimited(a, sep)
+	}
 	if len(a) != p.Len {
 		return fmt.Errorf("array length == %v, want %v", len(a), p.Len)
 	}
 	for i := range a {
 		err := p.ElemUnmarshaler.Unmarshal(v.Index(i), a[i:i+1], opts)
 		if err != nil {
-			return fmt.Errorf("error unmarshaling array index %v :: %v", i, err)
+			return fmt.Errorf("error unmarshaling array index %v :: %w", i, err)
 		}
 	}
 	return nil
@@ -91,13 +106,21 @@ type sliceUnmarshaler struct {
 
 func newSliceUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (Unmarshaler, error) {
 	if t.Kind() != reflect.Slice {
-		return nil, &wrongKindError{Expected: reflect.Slice, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Slice, Actual: t}
+	}
+	if isRecursiveElemType(t) {
+		return nil, &UnhandledTypeError{Type: t}
 	}
 
 	eu, err := opts.UnmarshalerFactory.Unmarshaler(t.Elem(), opts)
 	if err != nil {
 		return nil, err
 	}
+	if isMultiValueUnmarshaler(eu) {
+		// The items of nested arrays and slices have to be unmarshaled from
+		// explicit indices by an indexedUnmarshaler.
+		return nil, &UnhandledTypeError{Type: t}
+	}
 	return &sliceUnmarshaler{
 		Type:            t,
 		ElemUnmarshaler: eu,
@@ -107,20 +130,108 @@ func newSliceUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (Unmarshaler, e
 func (p *sliceUnmarshaler) Unmarshal(v reflect.Value, a []string, opts *UnmarshalOptions) error {
 	t := v.Type()
 	if t != p.Type {
-		return &wrongTypeError{Actual: t, Expected: p.Type}
+		return &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 
-	if v.IsNil() {
+	if sep, ok := opts.DefaultStyle.delimiter(opts.DefaultExplode); ok {
+		a = splitDelimited(a, sep)
+	}
+
+	// A nil a (e.g.: a missing key with the opt option) leaves non-nil
+	// slices alone. Otherwise the slice is replaced by the items of a.
+	if v.IsNil() || v.Len() < len(a) {
 		v.Set(reflect.MakeSlice(t, len(a), len(a)))
+	} else if a != nil {
+		v.SetLen(len(a))
 	}
 
 	for i := range a {
 		err := p.ElemUnmarshaler.Unmarshal(v.Index(i), a[i:i+1], opts)
 		if err != nil {
-			return fmt.Errorf("error unmarshaling slice index %v :: %v", i, err)
+			return fmt.Errorf("error unmarshaling slice index %v :: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// isMultiValueUnmarshaler returns true if u is one of the builtin array or
+// slice unmarshalers that consume all items of a []string, or a builtin
+// pointer or Optional unmarshaler that wraps one.
+func isMultiValueUnmarshaler(u Unmarshaler) bool {
+	switch u := u.(type) {
+	case *arrayUnmarshaler, *sliceUnmarshaler:
+		return true
+	case *ptrUnmarshaler:
+		return isMultiValueUnmarshaler(u.ElemUnmarshaler)
+	case *optionalUnmarshaler:
+		return isMultiValueUnmarshaler(u.ValueUnmarshaler)
+	default:
+		return false
+	}
+}
+
+// defaultValueUnmarshaler is implemented by the Unmarshalers that handle the
+// value of the default tag option differently from the values of the query
+// string.
+type defaultValueUnmarshaler interface {
+	// unmarshalDefault unmarshals the default value a of a field whose key
+	// is missing from the query string.
+	unmarshalDefault(v reflect.Value, a []string, opts *UnmarshalOptions) error
+}
+
+// unmarshalDefault unmarshals the default value of a field using u.
+func unmarshalDefault(u Unmarshaler, v reflect.Value, a []string, opts *UnmarshalOptions) error {
+	if du, ok := u.(defaultValueUnmarshaler); ok {
+		return du.unmarshalDefault(v, a, opts)
+	}
+	return u.Unmarshal(v, a, opts)
+}
+
+// optionalUnmarshaler implements the Unmarshaler interface for Optional[T].
+type optionalUnmarshaler struct {
+	Type             reflect.Type
+	ValueUnmarshaler Unmarshaler
+}
+
+func newOptionalUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (Unmarshaler, error) {
+	um, err := opts.UnmarshalerFactory.Unmarshaler(t.Field(optionalValueField).Type, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &optionalUnmarshaler{
+		Type:             t,
+		ValueUnmarshaler: um,
+	}, nil
+}
+
+func (p *optionalUnmarshaler) Unmarshal(v reflect.Value, a []string, opts *UnmarshalOptions) error {
+	t := v.Type()
+	if t != p.Type {
+		return &WrongTypeError{Actual: t, Expected: p.Type}
+	}
+	v.Set(reflect.Zero(t))
+	if a == nil {
+		return nil
+	}
+	v.Field(optionalPresentField).SetBool(true)
+	for _, s := range a {
+		if s != "" {
+			return p.ValueUnmarshaler.Unmarshal(v.Field(optionalValueField), a, opts)
 		}
 	}
+	v.Field(optionalEmptyField).SetBool(true)
+	return nil
+}
 
+// unmarshalDefault sets the Value of the Optional but leaves it not Present
+// because the key of the field isn't in the query string.
+func (p *optionalUnmarshaler) unmarshalDefault(v reflect.Value, a []string, opts *UnmarshalOptions) error {
+	if err := p.Unmarshal(v, a, opts); err != nil {
+		return err
+	}
+	v.Field(optionalPresentField).SetBool(false)
+	v.Field(optionalEmptyField).SetBool(false)
 	return nil
 }
 
@@ -128,7 +239,7 @@ func (p *sliceUnmarshaler) Unmarshal(v reflect.Value, a []string, opts *Unmarsha
 // underlying type (kind) of string.
 func unmarshalString(v reflect.Value, s string, opts *UnmarshalOptions) error {
 	if v.Kind() != reflect.String {
-		return &wrongKindError{Expected: reflect.String, Actual: v.Type()}
+		return &WrongKindError{Expected: reflect.String, Actual: v.Type()}
 	}
 	v.SetString(s)
 	return nil
@@ -138,7 +249,7 @@ func unmarshalString(v reflect.Value, s string, opts *UnmarshalOptions) error {
 // underlying type (kind) of bool.
 func unmarshalBool(v reflect.Value, s string, opts *UnmarshalOptions) error {
 	if v.Kind() != reflect.Bool {
-		return &wrongKindError{Expected: reflect.Bool, Actual: v.Type()}
+		return &WrongKindError{Expected: reflect.Bool, Actual: v.Type()}
 	}
 	b, err := strconv.ParseBool(s)
 	if err != nil {
@@ -164,7 +275,7 @@ func unmarshalInt(v reflect.Value, s string, opts *UnmarshalOptions) error {
 	case reflect.Int64:
 		bitSize = 64
 	default:
-		return &wrongKindError{Expected: reflect.Int, Actual: v.Type()}
+		return &WrongKindError{Expected: reflect.Int, Actual: v.Type()}
 	}
 
 	i, err := strconv.ParseInt(s, 0, bitSize)
@@ -192,7 +303,7 @@ func unmarshalUint(v reflect.Value, s string, opts *UnmarshalOptions) error {
 	case reflect.Uint64:
 		bitSize = 64
 	default:
-		return &wrongKindError{Expected: reflect.Uint, Actual: v.Type()}
+		return &WrongKindError{Expected: reflect.Uint, Actual: v.Type()}
 	}
 
 	i, err := strconv.ParseUint(s, 0, bitSize)
@@ -213,7 +324,7 @@ func unmarshalFloat(v reflect.Value, s string, opts *UnmarshalOptions) error {
 	case reflect.Float64:
 		bitSize = 64
 	default:
-		return &wrongKindError{Expected: reflect.Float32, Actual: v.Type()}
+		return &WrongKindError{Expected: reflect.Float32, Actual: v.Type()}
 	}
 
 	f, err := strconv.ParseFloat(s, bitSize)
@@ -228,10 +339,10 @@ func unmarshalFloat(v reflect.Value, s string, opts *UnmarshalOptions) error {
 func unmarshalTime(v reflect.Value, s string, opts *UnmarshalOptions) error {
 	t := v.Type()
 	if t != timeType {
-		return &wrongTypeError{Actual: t, Expected: timeType}
+		return &WrongTypeError{Actual: t, Expected: timeType}
 	}
 
-	tm, err := time.Parse(time.RFC3339, s)
+	tm, err := parseTime(s, opts.TimeLayout, opts.TimeLocation)
 	if err != nil {
 		return err
 	}
@@ -239,10 +350,30 @@ func unmarshalTime(v reflect.Value, s string, opts *UnmarshalOptions) error {
 	return nil
 }
 
+func unmarshalDuration(v reflect.Value, s string, opts *UnmarshalOptions) error {
+	t := v.Type()
+	if t != durationType {
+		return &WrongTypeError{Actual: t, Expected: durationType}
+	}
+
+	// Integers are accepted as nanoseconds because older versions of the
+	// package marshaled time.Duration values as plain int64 values.
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		v.SetInt(i)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	v.SetInt(int64(d))
+	return nil
+}
+
 func unmarshalURL(v reflect.Value, s string, opts *UnmarshalOptions) error {
 	t := v.Type()
 	if t != urlType {
-		return &wrongTypeError{Actual: t, Expected: urlType}
+		return &WrongTypeError{Actual: t, Expected: urlType}
 	}
 
 	u, err := url.Parse(s)
@@ -263,3 +394,14 @@ func unmarshalWithUnmarshalQS(v reflect.Value, a []string, opts *UnmarshalOption
 	}
 	return unmarshalQS.UnmarshalQS(a, opts)
 }
+
+func unmarshalWithUnmarshalText(v reflect.Value, s string, opts *UnmarshalOptions) error {
+	if !v.CanAddr() {
+		return fmt.Errorf("expected and addressable value, got %v", v)
+	}
+	textUnmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	if !ok {
+		return fmt.Errorf("expected a type that implements encoding.TextUnmarshaler, got %v", v.Type())
+	}
+	return textUnmarshaler.UnmarshalText([]byte(s))
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -1205,3 +1208,1210 @@ func TestCheckUnmarshalType(t *testing.T) {
 		t.Error("unexpected success")
 	}
 }
+
+type UNestedInner struct {
+	Status string
+	Owner  string
+}
+
+type UNested struct {
+	Filter    UNestedInner
+	FilterPtr *UNestedInner `qs:"filter_ptr"`
+	NilPtr    *UNestedInner `qs:",nil"`
+	Outer     struct {
+		Inner UNestedInner
+	}
+}
+
+func TestUnmarshalNestedStruct(t *testing.T) {
+	var us UNested
+	err := Unmarshal(&us, "filter.status=open&filter.owner=me&filter_ptr.status=closed&outer.inner.status=any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr comparisonResults
+	cr.compare("filter.status", us.Filter.Status, "open")
+	cr.compare("filter.owner", us.Filter.Owner, "me")
+	cr.compare("filter_ptr", us.FilterPtr != nil, true)
+	if us.FilterPtr != nil {
+		cr.compare("filter_ptr.status", us.FilterPtr.Status, "closed")
+	}
+	cr.compare("nil_ptr", us.NilPtr, nil)
+	cr.compare("outer.inner.status", us.Outer.Inner.Status, "any")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+type UNestedReq struct {
+	Filter struct {
+		Status string `qs:",req"`
+	}
+}
+
+func TestUnmarshalNestedStructReq(t *testing.T) {
+	var us UNestedReq
+	err := Unmarshal(&us, "filter.owner=me")
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	name, ok := IsRequiredFieldError(err)
+	if !ok {
+		t.Fatalf("expected a RequiredFieldError :: %v", err)
+	}
+	if name != "filter.status" {
+		t.Errorf("name == %q, want %q", name, "filter.status")
+	}
+}
+
+type UNestedMissing struct {
+	Page   int
+	Filter *struct {
+		Status string `qs:",req"`
+		Limit  int    `qs:",default=10"`
+	}
+	NilFilter *struct {
+		Status string
+	} `qs:",nil"`
+	Items []ULineItem
+}
+
+func TestUnmarshalMissingNestedStruct(t *testing.T) {
+	var us UNestedMissing
+	if err := Unmarshal(&us, "page=3"); err != nil {
+		t.Fatal(err)
+	}
+	var cr comparisonResults
+	cr.compare("page", us.Page, 3)
+	cr.compare("filter", us.Filter != nil, true)
+	if us.Filter != nil {
+		cr.compare("filter.limit", us.Filter.Limit, 0)
+	}
+	cr.compare("nil_filter", us.NilFilter == nil, true)
+	cr.compare("items", us.Items != nil && len(us.Items) == 0, true)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalNestedStructBracketSyntax(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		KeySyntax: BracketSyntax,
+	})
+	var us UNested
+	err := unmarshaler.Unmarshal(&us, "filter[status]=open&filter_ptr[owner]=me&outer[inner][status]=any&filter.owner=ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr comparisonResults
+	cr.compare("filter.status", us.Filter.Status, "open")
+	cr.compare("filter.owner", us.Filter.Owner, "")
+	cr.compare("filter_ptr", us.FilterPtr != nil, true)
+	if us.FilterPtr != nil {
+		cr.compare("filter_ptr.owner", us.FilterPtr.Owner, "me")
+	}
+	cr.compare("outer.inner.status", us.Outer.Inner.Status, "any")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+type ULineItem struct {
+	Name string
+	Qty  int
+}
+
+type UIndexed struct {
+	Items    []ULineItem
+	ItemPtrs []*ULineItem `qs:"item_ptrs"`
+	Pairs    [2]ULineItem
+	Matrix   [][]int
+}
+
+func TestUnmarshalIndexedSlices(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		KeySyntax: BracketSyntax,
+	})
+
+	var us UIndexed
+	err := unmarshaler.Unmarshal(&us, strings.Join([]string{
+		"items[2][name]=c",
+		"items[0][name]=a&items[0][qty]=2",
+		"item_ptrs[1][name]=p",
+		"pairs[1][name]=e",
+		"matrix[1]=3&matrix[0]=1&matrix[0]=2",
+	}, "&"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cr comparisonResults
+	cr.compare("len(items)", len(us.Items), 3)
+	if len(us.Items) == 3 {
+		cr.compare("items[0].name", us.Items[0].Name, "a")
+		cr.compare("items[0].qty", us.Items[0].Qty, 2)
+		cr.compare("items[1].name", us.Items[1].Name, "")
+		cr.compare("items[2].name", us.Items[2].Name, "c")
+	}
+	cr.compare("len(item_ptrs)", len(us.ItemPtrs), 2)
+	if len(us.ItemPtrs) == 2 {
+		cr.compare("item_ptrs[0]", us.ItemPtrs[0], nil)
+		cr.compare("item_ptrs[1]", us.ItemPtrs[1] != nil, true)
+		if us.ItemPtrs[1] != nil {
+			cr.compare("item_ptrs[1].name", us.ItemPtrs[1].Name, "p")
+		}
+	}
+	cr.compare("pairs[0].name", us.Pairs[0].Name, "")
+	cr.compare("pairs[1].name", us.Pairs[1].Name, "e")
+	cr.compare("len(matrix)", len(us.Matrix), 2)
+	if len(us.Matrix) == 2 {
+		cr.compare("matrix[0]", us.Matrix[0], []int{1, 2})
+		cr.compare("matrix[1]", us.Matrix[1], []int{3})
+	}
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalIndexedSlicesInvalidIndex(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		MaxSliceIndex: 10,
+	})
+
+	for _, queryStr := range []string{
+		"items[11].name=a",
+		"items[99999999].name=a",
+		"items[-1].name=a",
+		"items[x].name=a",
+		"pairs[2].name=a",
+	} {
+		var us UIndexed
+		if err := unmarshaler.Unmarshal(&us, queryStr); err == nil {
+			t.Errorf("unexpected success - query string: %q", queryStr)
+		}
+	}
+}
+
+func TestUnmarshalIndexedSlicesPrefilled(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		KeySyntax: BracketSyntax,
+	})
+
+	us := UIndexed{
+		Items:  []ULineItem{{Name: "x", Qty: 1}, {Name: "y"}, {Name: "z"}},
+		Matrix: [][]int{{7}, {8}, {9}},
+	}
+	err := unmarshaler.Unmarshal(&us, "items[0][name]=a&matrix[1]=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cr comparisonResults
+	cr.compare("len(items)", len(us.Items), 1)
+	if len(us.Items) == 1 {
+		cr.compare("items[0].name", us.Items[0].Name, "a")
+		cr.compare("items[0].qty", us.Items[0].Qty, 1)
+	}
+	cr.compare("len(matrix)", len(us.Matrix), 2)
+	if len(us.Matrix) == 2 {
+		cr.compare("matrix[0]", us.Matrix[0], []int{7})
+		cr.compare("matrix[1]", us.Matrix[1], []int{3})
+	}
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalIndexedSlicesInvalidIndexCollectErrors(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		MaxSliceIndex: 10,
+		CollectErrors: true,
+	})
+
+	var us UIndexed
+	err := unmarshaler.Unmarshal(&us, "items[11].name=a&items[x].name=b&items[1].qty=q&items[0].name=c&matrix[y]=1")
+	var me *MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected a MultiError :: %v", err)
+	}
+
+	var keys []string
+	for _, fe := range me.FieldErrors() {
+		keys = append(keys, fe.Key)
+	}
+	sort.Strings(keys)
+	var cr comparisonResults
+	cr.compare("keys", keys, []string{"items[11]", "items[1].qty", "items[x]", "matrix[y]"})
+	cr.compare("len(items)", len(us.Items), 2)
+	if len(us.Items) == 2 {
+		cr.compare("items[0].name", us.Items[0].Name, "c")
+	}
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalIndexedSlicesReq(t *testing.T) {
+	var us struct {
+		Items []struct {
+			Name string `qs:",req"`
+		}
+	}
+	err := Unmarshal(&us, "items[0].name=a&items[1].qty=1")
+	name, ok := IsRequiredFieldError(err)
+	if !ok {
+		t.Fatalf("expected a RequiredFieldError :: %v", err)
+	}
+	if name != "items[1].name" {
+		t.Errorf("name == %q, want %q", name, "items[1].name")
+	}
+}
+
+type UMapFields struct {
+	Filters map[string]string
+	Ranges  map[string][]int
+	Items   map[string]ULineItem
+}
+
+func TestUnmarshalMapFields(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		KeySyntax: BracketSyntax,
+	})
+
+	var us UMapFields
+	err := unmarshaler.Unmarshal(&us, strings.Join([]string{
+		"filters[status]=open&filters[owner]=me",
+		"ranges[size]=1&ranges[size]=10",
+		"items[x][name]=a&items[x][qty]=2&items[y][name]=b",
+	}, "&"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cr comparisonResults
+	cr.compare("len(filters)", len(us.Filters), 2)
+	cr.compare("filters[status]", us.Filters["status"], "open")
+	cr.compare("filters[owner]", us.Filters["owner"], "me")
+	cr.compare("len(ranges)", len(us.Ranges), 1)
+	cr.compare("ranges[size]", us.Ranges["size"], []int{1, 10})
+	cr.compare("len(items)", len(us.Items), 2)
+	cr.compare("items[x]", us.Items["x"], ULineItem{Name: "a", Qty: 2})
+	cr.compare("items[y]", us.Items["y"], ULineItem{Name: "b"})
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalMapFieldsReq(t *testing.T) {
+	var us struct {
+		Items map[string]struct {
+			Name string `qs:",req"`
+		}
+	}
+	err := Unmarshal(&us, "items.x.qty=1")
+	name, ok := IsRequiredFieldError(err)
+	if !ok {
+		t.Fatalf("expected a RequiredFieldError :: %v", err)
+	}
+	if name != "items.x.name" {
+		t.Errorf("name == %q, want %q", name, "items.x.name")
+	}
+}
+
+type UStyles struct {
+	Default   []int
+	Form      []int             `qs:"form,explode=false"`
+	Space     []int             `qs:"space,style=spaceDelimited"`
+	Pipe      []int             `qs:"pipe,style=pipeDelimited"`
+	PipeArr   [2]int            `qs:"pipe_arr,style=pipeDelimited"`
+	Exploded  []int             `qs:"exploded,style=pipeDelimited,explode=true"`
+	Filter    UNestedInner      `qs:"filter,style=deepObject"`
+	FilterMap map[string]string `qs:"filter_map,style=deepObject"`
+}
+
+func TestUnmarshalStyles(t *testing.T) {
+	var us UStyles
+	err := Unmarshal(&us, strings.Join([]string{
+		"default=1&default=2",
+		"form=1,2",
+		"space=1%202",
+		"pipe=1|2",
+		"pipe_arr=3|4",
+		"exploded=1&exploded=2",
+		"filter[status]=open&filter.owner=ignored",
+		"filter_map[owner]=me",
+	}, "&"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cr comparisonResults
+	cr.compare("default", us.Default, []int{1, 2})
+	cr.compare("form", us.Form, []int{1, 2})
+	cr.compare("space", us.Space, []int{1, 2})
+	cr.compare("pipe", us.Pipe, []int{1, 2})
+	cr.compare("pipe_arr[0]", us.PipeArr[0], 3)
+	cr.compare("pipe_arr[1]", us.PipeArr[1], 4)
+	cr.compare("exploded", us.Exploded, []int{1, 2})
+	cr.compare("filter.status", us.Filter.Status, "open")
+	cr.compare("filter.owner", us.Filter.Owner, "")
+	cr.compare("len(filter_map)", len(us.FilterMap), 1)
+	cr.compare("filter_map[owner]", us.FilterMap["owner"], "me")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalPrefilledSlices(t *testing.T) {
+	us := UStyles{
+		Default:  []int{9},
+		Form:     []int{9},
+		Pipe:     []int{9, 9, 9},
+		Exploded: []int{7, 8},
+	}
+	err := Unmarshal(&us, "default=1&default=2&form=1,2,3&pipe=1|2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cr comparisonResults
+	cr.compare("default", us.Default, []int{1, 2})
+	cr.compare("form", us.Form, []int{1, 2, 3})
+	cr.compare("pipe", us.Pipe, []int{1, 2})
+	cr.compare("exploded", us.Exploded, []int{7, 8})
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalDefaultStyle(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		DefaultStyle: PipeDelimitedStyle,
+	})
+	var us struct {
+		Ids   []int
+		Empty []int
+	}
+	err := unmarshaler.Unmarshal(&us, "ids=1|2|3&empty=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr comparisonResults
+	cr.compare("ids", us.Ids, []int{1, 2, 3})
+	cr.compare("len(empty)", len(us.Empty), 0)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+var errUFailing = errors.New("failing value")
+
+type UFailing struct{}
+
+func (p *UFailing) UnmarshalQS(a []string, opts *UnmarshalOptions) error {
+	if a == nil {
+		return nil
+	}
+	return errUFailing
+}
+
+type UFieldErrorEmbedded struct {
+	Embedded UFailing
+}
+
+type UFieldErrorItem struct {
+	Name UFailing
+}
+
+type UFieldError struct {
+	UFieldErrorEmbedded
+	Count  int
+	Items  []UFieldErrorItem
+	Lookup map[string]UFieldErrorItem
+}
+
+func TestUnmarshalFieldError(t *testing.T) {
+	for _, tc := range []struct {
+		queryStr string
+		field    string
+		key      string
+		values   []string
+		cause    error
+	}{
+		{"count=x", "Count", "count", []string{"x"}, nil},
+		{"embedded=x", "UFieldErrorEmbedded.Embedded", "embedded", []string{"x"}, errUFailing},
+		{"items[1].name=x", "Items[1].Name", "items[1].name", []string{"x"}, errUFailing},
+		{"lookup.a.name=x", `Lookup["a"].Name`, "lookup.a.name", []string{"x"}, errUFailing},
+	} {
+		var us UFieldError
+		err := Unmarshal(&us, tc.queryStr)
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			t.Errorf("query string %q: expected a FieldError :: %v", tc.queryStr, err)
+			continue
+		}
+		var cr comparisonResults
+		cr.compare("Struct", fe.Struct == reflect.TypeOf(us), true)
+		cr.compare("Field", fe.Field, tc.field)
+		cr.compare("Key", fe.Key, tc.key)
+		cr.compare("Values", fe.Values, tc.values)
+		if err := cr.finish(); err != nil {
+			t.Errorf("query string %q :: %v", tc.queryStr, err)
+		}
+		if tc.cause != nil && !errors.Is(err, tc.cause) {
+			t.Errorf("query string %q: unexpected cause :: %v", tc.queryStr, err)
+		}
+	}
+}
+
+func TestUnmarshalFieldErrorRequired(t *testing.T) {
+	var us UNestedReq
+	err := Unmarshal(&us, "filter.owner=me")
+	if !errors.Is(err, ErrRequiredField) {
+		t.Fatalf("expected ErrRequiredField :: %v", err)
+	}
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected a FieldError :: %v", err)
+	}
+	var cr comparisonResults
+	cr.compare("Field", fe.Field, "Filter.Status")
+	cr.compare("Key", fe.Key, "filter.status")
+	cr.compare("Values", fe.Values, []string(nil))
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+type UCollectErrors struct {
+	UFieldErrorEmbedded
+	Count  int
+	Req    string `qs:",req"`
+	Items  []UFieldErrorItem
+	Filter struct {
+		Status string `qs:",req"`
+		Owner  string
+	}
+	Valid string
+}
+
+func TestUnmarshalCollectErrors(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		CollectErrors: true,
+	})
+
+	var us UCollectErrors
+	err := unmarshaler.Unmarshal(&us, "count=x&items[0].name=x&items[1].name=y&filter.owner=me&embedded=x&valid=ok")
+	var me *MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected a MultiError :: %v", err)
+	}
+
+	var keys []string
+	for _, fe := range me.FieldErrors() {
+		keys = append(keys, fe.Key)
+	}
+	var cr comparisonResults
+	cr.compare("keys", keys, []string{"count", "req", "items[0].name", "items[1].name", "filter.status", "embedded"})
+	cr.compare("missing", me.MissingFields(), []string{"req", "filter.status"})
+	cr.compare("filter.owner", us.Filter.Owner, "me")
+	cr.compare("valid", us.Valid, "ok")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	name, ok := IsRequiredFieldError(err)
+	if !ok || name != "req" {
+		t.Errorf("IsRequiredFieldError() == (%q, %v), want (%q, true)", name, ok, "req")
+	}
+
+	us = UCollectErrors{}
+	err = unmarshaler.Unmarshal(&us, "req=a&filter.status=open")
+	if err != nil {
+		t.Errorf("unexpected error :: %v", err)
+	}
+}
+
+type UStrictEmbedded struct {
+	Page int
+}
+
+type UStrict struct {
+	UStrictEmbedded
+	PageSize int
+	Tags     []string
+	Filter   UNestedInner
+	Items    []ULineItem
+	Labels   map[string]string
+}
+
+func TestUnmarshalDisallowUnknownKeys(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		DisallowUnknownKeys: true,
+		AllowedUnknownKeys:  []string{"utm_*"},
+	})
+
+	var us UStrict
+	err := unmarshaler.Unmarshal(&us, strings.Join([]string{
+		"page=1&page_size=50&tags=a&tags=b",
+		"filter.status=open&items[0].name=a&labels.x=y",
+		"utm_source=mail&utm_medium=web",
+	}, "&"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	us = UStrict{}
+	err = unmarshaler.Unmarshal(&us, "page=1&pagesize=50&filter.state=open&items[0].title=a&tags[]=a")
+	var uke *UnknownKeysError
+	if !errors.As(err, &uke) {
+		t.Fatalf("expected an UnknownKeysError :: %v", err)
+	}
+	var cr comparisonResults
+	cr.compare("keys", uke.Keys, []string{"filter.state", "items[0].title", "pagesize", "tags[]"})
+	cr.compare("page", us.Page, 0)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalDisallowUnknownKeysCollectErrors(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		DisallowUnknownKeys: true,
+		CollectErrors:       true,
+	})
+
+	var us UStrict
+	err := unmarshaler.Unmarshal(&us, "page=x&pagesize=50")
+	var me *MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected a MultiError :: %v", err)
+	}
+	if len(me.Errors) != 2 {
+		t.Fatalf("len(Errors) == %v, want 2 :: %v", len(me.Errors), err)
+	}
+	var fe *FieldError
+	if !errors.As(me.Errors[0], &fe) || fe.Key != "page" {
+		t.Errorf("expected a FieldError for key %q :: %v", "page", me.Errors[0])
+	}
+	var uke *UnknownKeysError
+	if !errors.As(me.Errors[1], &uke) {
+		t.Errorf("expected an UnknownKeysError :: %v", me.Errors[1])
+	}
+
+	// The error of the Validate hook isn't a MultiError.
+	var ur URange
+	err = unmarshaler.Unmarshal(&ur, "from=2&to=1&x=1")
+	if !errors.As(err, &me) || len(me.Errors) != 2 {
+		t.Fatalf("expected a MultiError with 2 errors :: %v", err)
+	}
+	if !strings.Contains(me.Errors[0].Error(), "from > to") {
+		t.Errorf("expected the Validate error :: %v", me.Errors[0])
+	}
+	if !errors.As(me.Errors[1], &uke) {
+		t.Errorf("expected an UnknownKeysError :: %v", me.Errors[1])
+	}
+}
+
+type URemain struct {
+	Page   int
+	Filter struct {
+		Status string
+		Extra  map[string][]string `qs:",remain"`
+	}
+	Extra url.Values `qs:",remain"`
+}
+
+func TestUnmarshalRemain(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{
+		DisallowUnknownKeys: true,
+	})
+
+	var us URemain
+	err := unmarshaler.Unmarshal(&us, "page=2&filter.status=open&filter.owner=me&x=1&x=2&utm_source=mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cr comparisonResults
+	cr.compare("page", us.Page, 2)
+	cr.compare("filter.status", us.Filter.Status, "open")
+	cr.compare("len(filter.extra)", len(us.Filter.Extra), 1)
+	cr.compare("filter.extra[owner]", us.Filter.Extra["owner"], []string{"me"})
+	cr.compare("len(extra)", len(us.Extra), 2)
+	cr.compare("extra[x]", us.Extra["x"], []string{"1", "2"})
+	cr.compare("extra[utm_source]", us.Extra["utm_source"], []string{"mail"})
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	queryStr, err := Marshal(&us)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "filter.owner=me&filter.status=open&page=2&utm_source=mail&x=1&x=2"
+	if queryStr != want {
+		t.Errorf("got %q, want %q", queryStr, want)
+	}
+}
+
+type UDefaults struct {
+	PageSize int      `qs:",default=50"`
+	Sort     string   `qs:",default=name"`
+	Ptr      *int     `qs:",nil,default=5"`
+	IDs      []int    `qs:"ids,style=pipeDelimited,default=1|2"`
+	Empty    string   `qs:",default="`
+	Explicit *float64 `qs:",default=1.5"`
+}
+
+func TestUnmarshalDefaults(t *testing.T) {
+	us := UDefaults{Empty: "x"}
+	err := Unmarshal(&us, "explicit=2.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr comparisonResults
+	cr.compare("page_size", us.PageSize, 50)
+	cr.compare("sort", us.Sort, "name")
+	cr.compare("ptr", us.Ptr, 5)
+	cr.compare("ids", us.IDs, []int{1, 2})
+	cr.compare("empty", us.Empty, "")
+	cr.compare("explicit", us.Explicit, 2.5)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckUnmarshalInvalidDefault(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			A int `qs:",default=x"`
+		}{},
+		&struct {
+			A int `qs:",req,default=1"`
+		}{},
+		&struct {
+			A struct{ B int } `qs:",default=1"`
+		}{},
+	} {
+		if err := CheckUnmarshal(v); err == nil {
+			t.Errorf("unexpected success - type: %T", v)
+		}
+	}
+}
+
+type UConstraints struct {
+	Page   int      `qs:",min=1,max=100"`
+	Ratio  *float64 `qs:",min=0,max=1"`
+	Code   string   `qs:",len=3"`
+	Name   string   `qs:",maxlen=5"`
+	Slug   string   `qs:",pattern=^[a-z-]+$"`
+	Sort   string   `qs:",oneof=name|date"`
+	IDs    []int    `qs:"ids,min=1,maxlen=3"`
+	Status string   `qs:",oneof=open|closed,default=open"`
+	Zip    string   `qs:",pattern=^[0-9]{4,5}$"`
+	Tag    string   `qs:",pattern=[a-z]"`
+}
+
+func TestUnmarshalConstraints(t *testing.T) {
+	var us UConstraints
+	err := Unmarshal(&us, "page=100&ratio=0.5&code=abc&name=%C3%A1rv%C3%ADz&slug=a-b&sort=date&ids=1&ids=2&zip=1234&tag=A1b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		queryStr   string
+		key        string
+		constraint string
+	}{
+		{"page=0", "page", "min"},
+		{"page=101", "page", "max"},
+		{"ratio=1.5", "ratio", "max"},
+		{"code=ab", "code", "len"},
+		{"name=abcdef", "name", "maxlen"},
+		{"slug=A", "slug", "pattern"},
+		{"zip=123", "zip", "pattern"},
+		{"zip=123456", "zip", "pattern"},
+		{"tag=A1", "tag", "pattern"},
+		{"sort=size", "sort", "oneof"},
+		{"ids=1&ids=0", "ids", "min"},
+		{"ids=1&ids=2&ids=3&ids=4", "ids", "maxlen"},
+	} {
+		us = UConstraints{}
+		err := Unmarshal(&us, tc.queryStr)
+		var fe *FieldError
+		var ce *ConstraintError
+		if !errors.As(err, &fe) || !errors.As(err, &ce) {
+			t.Errorf("query string %q: expected a FieldError with a ConstraintError :: %v", tc.queryStr, err)
+			continue
+		}
+		if fe.Key != tc.key || ce.Constraint != tc.constraint {
+			t.Errorf("query string %q: got key %q and constraint %q, want %q and %q",
+				tc.queryStr, fe.Key, ce.Constraint, tc.key, tc.constraint)
+		}
+	}
+}
+
+func TestCheckUnmarshalInvalidConstraints(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			A string `qs:",min=1"`
+		}{},
+		&struct {
+			A int `qs:",maxlen=1"`
+		}{},
+		&struct {
+			A int `qs:",pattern=x"`
+		}{},
+		&struct {
+			A string `qs:",pattern=("`
+		}{},
+		&struct {
+			A int `qs:",min=x"`
+		}{},
+		&struct {
+			A string `qs:",len=-1"`
+		}{},
+	} {
+		if err := CheckUnmarshal(v); err == nil {
+			t.Errorf("unexpected success - type: %T", v)
+		}
+	}
+}
+
+// URange implements the AfterUnmarshalQS and Validate interfaces.
+type URange struct {
+	From int
+	To   int
+	Span int `qs:"-"`
+}
+
+func (p *URange) AfterUnmarshalQS(opts *UnmarshalOptions) error {
+	p.Span = p.To - p.From
+	return nil
+}
+
+func (p URange) Validate() error {
+	if p.From > p.To {
+		return errors.New("from > to")
+	}
+	return nil
+}
+
+func TestUnmarshalHooks(t *testing.T) {
+	type s struct {
+		Range URange
+	}
+
+	var r URange
+	if err := Unmarshal(&r, "from=1&to=5"); err != nil {
+		t.Fatal(err)
+	}
+	if r.Span != 4 {
+		t.Errorf("Span == %v, want 4", r.Span)
+	}
+
+	var hs s
+	if err := Unmarshal(&hs, "range.from=2&range.to=4"); err != nil {
+		t.Fatal(err)
+	}
+	if hs.Range.Span != 2 {
+		t.Errorf("Span == %v, want 2", hs.Range.Span)
+	}
+
+	r = URange{}
+	err := Unmarshal(&r, "from=5&to=1")
+	var he *HookError
+	if !errors.As(err, &he) || he.Method != "Validate" || he.Struct != reflect.TypeOf(r) {
+		t.Errorf("expected a HookError of Validate :: %v", err)
+	}
+
+	hs = s{}
+	err = Unmarshal(&hs, "range.from=5&range.to=1")
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "Range" || !errors.As(err, &he) {
+		t.Errorf("expected a FieldError that wraps a HookError :: %v", err)
+	}
+
+	// The hooks aren't called after field errors.
+	r = URange{}
+	err = Unmarshal(&r, "from=x&to=1")
+	if !errors.As(err, &fe) || errors.As(err, &he) || r.Span != 0 {
+		t.Errorf("expected only a FieldError :: %v", err)
+	}
+}
+
+// UTextLevel implements the encoding.TextUnmarshaler interface.
+type UTextLevel int
+
+func (p *UTextLevel) UnmarshalText(text []byte) error {
+	s := string(text)
+	if !strings.HasPrefix(s, "level-") {
+		return fmt.Errorf("invalid level: %q", s)
+	}
+	i, err := strconv.Atoi(s[len("level-"):])
+	*p = UTextLevel(i)
+	return err
+}
+
+func TestUnmarshalTextUnmarshaler(t *testing.T) {
+	type s struct {
+		IP     net.IP
+		Level  UTextLevel
+		PLevel *UTextLevel
+		Levels []UTextLevel
+		Map    map[string]UTextLevel
+	}
+
+	var us s
+	err := Unmarshal(&us, "ip=127.0.0.1&level=level-1&p_level=level-2&levels=level-3&levels=level-4&map.a=level-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("IP", us.IP.String(), "127.0.0.1")
+	cr.compare("Level", us.Level, UTextLevel(1))
+	cr.compare("PLevel", us.PLevel, UTextLevel(2))
+	cr.compare("len(Levels)", len(us.Levels), 2)
+	if len(us.Levels) == 2 {
+		cr.compare("Levels[0]", us.Levels[0], UTextLevel(3))
+		cr.compare("Levels[1]", us.Levels[1], UTextLevel(4))
+	}
+	cr.compare("Map[a]", us.Map["a"], UTextLevel(5))
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	err = Unmarshal(&us, "level=x")
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Key != "level" {
+		t.Errorf("expected a FieldError :: %v", err)
+	}
+}
+
+func TestUnmarshalTimeLayouts(t *testing.T) {
+	type s struct {
+		Default time.Time
+		Date    time.Time           `qs:",layout=2006-01-02"`
+		Unix    time.Time           `qs:",unix"`
+		Milli   *time.Time          `qs:",unixmilli"`
+		Since   Optional[time.Time] `qs:",layout=2006-01-02"`
+		Timeout time.Duration
+	}
+
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	um := NewUnmarshaler(&UnmarshalOptions{TimeLocation: loc})
+	var us s
+	err := um.Unmarshal(&us, "default=2020-05-17T10:30:00Z&date=2020-05-17&unix=1589711400&milli=1589711400250&since=2020-05-17&timeout=1m30s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	cr := &comparisonResults{}
+	cr.compare("Default", us.Default.Equal(tm), true)
+	cr.compare("Date", us.Date.Equal(time.Date(2020, 5, 17, 0, 0, 0, 0, loc)), true)
+	cr.compare("Unix", us.Unix.Equal(tm), true)
+	cr.compare("Unix.Location", us.Unix.Location().String(), loc.String())
+	cr.compare("Milli", us.Milli != nil && us.Milli.Equal(tm.Add(250*time.Millisecond)), true)
+	cr.compare("Since", us.Since.Present && us.Since.Value.Equal(time.Date(2020, 5, 17, 0, 0, 0, 0, loc)), true)
+	cr.compare("Timeout", us.Timeout, 90*time.Second)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	um = NewUnmarshaler(&UnmarshalOptions{TimeLayout: "2006-01-02"})
+	var d struct{ D time.Time }
+	if err := um.Unmarshal(&d, "d=2020-05-17"); err != nil {
+		t.Fatal(err)
+	}
+	if !d.D.Equal(time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("D == %v, want 2020-05-17 in UTC", d.D)
+	}
+
+	if err := Unmarshal(&us, "unix=x"); err == nil {
+		t.Error("unexpected success")
+	}
+
+	// Integer durations are nanoseconds and the millisecond timestamps
+	// outside the range of UnixNano don't overflow.
+	us = s{}
+	if err := Unmarshal(&us, "timeout=1500000000&milli=-62135596800000"); err != nil {
+		t.Fatal(err)
+	}
+	cr.compare("integer Timeout", us.Timeout, 1500*time.Millisecond)
+	cr.compare("year 1 Milli", us.Milli != nil && us.Milli.Equal(time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)), true)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalMapKeyTypes(t *testing.T) {
+	type key string
+	type s struct {
+		Named  map[key]int
+		Ints   map[int8]string
+		Uints  map[uint]string
+		Levels map[UTextLevel]string
+		Nested map[int]struct{ A int }
+	}
+
+	var us s
+	err := Unmarshal(&us, "named.a=1&ints.-1=x&uints.2=y&levels.level-3=z&nested.4.a=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Named[a]", us.Named["a"], 1)
+	cr.compare("Ints[-1]", us.Ints[-1], "x")
+	cr.compare("Uints[2]", us.Uints[2], "y")
+	cr.compare("Levels[3]", us.Levels[3], "z")
+	cr.compare("Nested[4].A", us.Nested[4].A, 5)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	for _, tc := range []struct {
+		queryStr string
+		key      string
+	}{
+		{"ints.128=x", "128"},
+		{"uints.-1=y", "-1"},
+		{"levels.x=z", "x"},
+		{"nested.x.a=1", "x"},
+	} {
+		err := Unmarshal(&us, tc.queryStr)
+		var mke *MapKeyError
+		if !errors.As(err, &mke) || mke.Key != tc.key {
+			t.Errorf("query string %q: expected a MapKeyError with key %q :: %v", tc.queryStr, tc.key, err)
+		}
+	}
+}
+
+func TestUnmarshalValuesPresence(t *testing.T) {
+	type Base struct {
+		ID int
+	}
+	type filter struct {
+		Status string
+		Owner  string
+	}
+	type s struct {
+		Base
+		Name   string
+		Tags   []string
+		Page   int `qs:",default=1"`
+		Filter *filter
+		Other  filter
+	}
+
+	var us s
+	vs := url.Values{
+		"id":            {"1"},
+		"name":          {""},
+		"tags":          {"a"},
+		"filter.status": {""},
+	}
+	fs, err := UnmarshalValuesPresence(&us, vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := fs.Fields()
+	expected := []string{"Base.ID", "Filter", "Filter.Status", "Name", "Tags"}
+	if strings.Join(fields, ",") != strings.Join(expected, ",") {
+		t.Errorf("Fields() == %v, want %v", fields, expected)
+	}
+	cr := &comparisonResults{}
+	cr.compare("HasKey(filter.status)", fs.HasKey("filter.status"), true)
+	cr.compare("HasKey(filter.owner)", fs.HasKey("filter.owner"), false)
+	cr.compare("Has(Page)", fs.Has("Page"), false)
+	cr.compare("Page", us.Page, 1)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+type UShadowInner struct {
+	Name   string
+	Page   int
+	Filter struct{ Status string }
+	Extra  url.Values `qs:",remain"`
+}
+
+type UShadow struct {
+	UShadowInner
+	Name   string
+	Filter struct{ Owner string }
+}
+
+func TestUnmarshalShadowedEmbeddedFields(t *testing.T) {
+	var v UShadow
+	vs := url.Values{
+		"name":          {"outer"},
+		"page":          {"2"},
+		"filter.owner":  {"me"},
+		"filter.status": {"open"},
+		"x":             {"1"},
+	}
+	fs, err := UnmarshalValuesPresence(&v, vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Name", v.Name, "outer")
+	cr.compare("UShadowInner.Name", v.UShadowInner.Name, "")
+	cr.compare("UShadowInner.Page", v.UShadowInner.Page, 2)
+	cr.compare("Filter.Owner", v.Filter.Owner, "me")
+	cr.compare("UShadowInner.Filter.Status", v.UShadowInner.Filter.Status, "")
+	cr.compare("UShadowInner.Extra", v.UShadowInner.Extra.Encode(), "x=1")
+	cr.compare("presence Name", fs.Has("Name"), true)
+	cr.compare("presence UShadowInner.Name", fs.Has("UShadowInner.Name"), false)
+	cr.compare("presence UShadowInner.Page", fs.Has("UShadowInner.Page"), true)
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalShadowedEmbeddedFieldsBracketSyntax(t *testing.T) {
+	type inner struct {
+		Name  string
+		First string     `qs:"name.first"`
+		Extra url.Values `qs:",remain"`
+	}
+	type outer struct {
+		inner
+		Name string
+	}
+
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{KeySyntax: BracketSyntax})
+	var v outer
+	err := unmarshaler.UnmarshalValues(&v, url.Values{
+		"name":       {"outer"},
+		"name.first": {"a"},
+		"name.last":  {"b"},
+		"name[x]":    {"c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Name", v.Name, "outer")
+	cr.compare("inner.Name", v.inner.Name, "")
+	cr.compare("inner.First", v.inner.First, "a")
+	cr.compare("inner.Extra", v.inner.Extra.Encode(), "name.last=b")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+type URemainExtra struct {
+	Rest url.Values `qs:",remain"`
+}
+
+type URemainEmbedded struct {
+	*URemainExtra
+	Page   int
+	Filter struct{ Status string }
+}
+
+type URemainSibling struct {
+	Name string
+}
+
+type URemainSiblings struct {
+	URemainExtra
+	URemainSibling
+	Page int
+}
+
+func TestUnmarshalEmbeddedRemain(t *testing.T) {
+	var v URemainEmbedded
+	err := Unmarshal(&v, "page=1&x=2&filter.status=open&filter.owner=me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &comparisonResults{}
+	cr.compare("Page", v.Page, 1)
+	cr.compare("Filter.Status", v.Filter.Status, "open")
+	cr.compare("URemainExtra != nil", v.URemainExtra != nil, true)
+	if v.URemainExtra != nil {
+		cr.compare("Rest", v.Rest.Encode(), "filter.owner=me&x=2")
+	}
+
+	var vs URemainSiblings
+	err = Unmarshal(&vs, "page=1&name=a&x=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr.compare("siblings Page", vs.Page, 1)
+	cr.compare("siblings Name", vs.Name, "a")
+	cr.compare("siblings Rest", vs.Rest.Encode(), "x=2")
+	if err := cr.finish(); err != nil {
+		t.Error(err)
+	}
+
+	type twoRemains struct {
+		URemainExtra
+		Extra url.Values `qs:",remain"`
+	}
+	if err := CheckUnmarshalType(reflect.TypeOf(twoRemains{})); err == nil {
+		t.Error("unexpected success with an embedded and an outer remain field")
+	}
+}
+
+func TestUnmarshalEmbeddedRemainUnknownKeys(t *testing.T) {
+	unmarshaler := NewUnmarshaler(&UnmarshalOptions{DisallowUnknownKeys: true})
+
+	// The keys that aren't consumed by the other fields are consumed by the
+	// remain field of the embedded struct.
+	var v URemainEmbedded
+	if err := unmarshaler.Unmarshal(&v, "page=1&bogus=1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Rest.Encode(); got != "bogus=1" {
+		t.Errorf("got Rest %q, want %q", got, "bogus=1")
+	}
+
+	// The shadowed keys aren't passed to the embedded struct so they are
+	// unknown if they aren't consumed by the outer struct.
+	var vs UShadow
+	var uke *UnknownKeysError
+	err := unmarshaler.Unmarshal(&vs, "page=1&filter.bogus=1")
+	if !errors.As(err, &uke) || len(uke.Keys) != 1 || uke.Keys[0] != "filter.bogus" {
+		t.Errorf("got error %v, want unknown key filter.bogus", err)
+	}
+
+	// A remain field in a nested struct consumes only the keys of the nested
+	// struct.
+	var vn struct {
+		Filter URemainExtra
+	}
+	err = unmarshaler.Unmarshal(&vn, "filter.x=1&bogus=1")
+	if !errors.As(err, &uke) || len(uke.Keys) != 1 || uke.Keys[0] != "bogus" {
+		t.Errorf("got error %v, want unknown key bogus", err)
+	}
+}
+
+func TestCheckUnmarshalDuplicateKeys(t *testing.T) {
+	type inner1 struct{ Name string }
+	type inner2 struct{ Name string }
+	for _, v := range []interface{}{
+		&struct {
+			HTTPServer string
+			HttpServer string
+		}{},
+		&struct {
+			Inner1 inner1 `qs:"x"`
+			X      string
+		}{},
+		&struct {
+			*inner1
+			inner2
+		}{},
+	} {
+		err := CheckUnmarshal(v)
+		if err == nil || !strings.Contains(err.Error(), "have the same key") {
+			t.Errorf("unexpected error: %v - type: %T", err, v)
+		}
+	}
+}
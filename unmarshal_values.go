@@ -4,179 +4,495 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 )
 
-// structUnmarshaler implements ValuesUnmarshaler.
+// structUnmarshaler implements ValuesUnmarshaler. It is also used by
+// UnmarshalHeader with the header tag key.
 type structUnmarshaler struct {
-	Type           reflect.Type
+	Type reflect.Type
+	// TagKey is the struct tag key of the fields: tagKey or headerTagKey.
+	TagKey         string
 	EmbeddedFields []embeddedFieldUnmarshaler
 	Fields         []*fieldUnmarshaler
+	// RemainField is the field with the remain tag option that receives
+	// the keys that aren't consumed by the other fields. It is nil if the
+	// struct doesn't have such a field.
+	RemainField *fieldUnmarshaler
+	// Remain is true if the struct or one of its embedded structs has a
+	// field with the remain tag option.
+	Remain bool
+	// AfterUnmarshal is true if the struct implements AfterUnmarshalQS.
+	AfterUnmarshal bool
+	// Validate is true if the struct implements Validate.
+	Validate bool
+	// Keys holds the keys of the visible fields including the fields of
+	// the embedded structs.
+	Keys []fieldKey
 }
 
 type embeddedFieldUnmarshaler struct {
 	FieldIndex        int
 	ValuesUnmarshaler ValuesUnmarshaler
+	// ShadowedKeys holds the keys of the embedded struct that are shadowed
+	// by the fields of the outer struct. They are removed from the
+	// url.Values unmarshaled by the embedded struct.
+	ShadowedKeys map[string]bool
+	// Remain is true if the embedded struct has a field with the remain tag
+	// option. The keys consumed by the other fields of the outer struct are
+	// removed from the url.Values unmarshaled by such embedded structs.
+	Remain bool
 }
 
 type fieldUnmarshaler struct {
 	FieldIndex  int
 	Unmarshaler Unmarshaler
-	Tag         parsedTag
+	// ValuesUnmarshaler is used instead of Unmarshaler in case of nested
+	// fields (e.g.: non-embedded structs) that are unmarshaled from several
+	// keys prefixed with the name of the field.
+	ValuesUnmarshaler ValuesUnmarshaler
+	Tag               parsedTag
+	// Opts holds the options of the field precomputed from BaseOpts which
+	// are the options the struct unmarshaler was created with.
+	Opts     *UnmarshalOptions
+	BaseOpts *UnmarshalOptions
+}
+
+// options returns the options of the field derived from opts. It avoids
+// copying the options on each call unless opts differs from the options the
+// struct unmarshaler was created with (e.g.: nested structs in fields whose
+// tag overrides the style).
+func (p *fieldUnmarshaler) options(opts *UnmarshalOptions) *UnmarshalOptions {
+	if opts == p.BaseOpts {
+		return p.Opts
+	}
+	return opts.fieldOptions(p.Tag)
 }
 
 // newStructUnmarshaler creates a struct unmarshaler for a specific struct type.
 func newStructUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (ValuesUnmarshaler, error) {
+	return newTaggedStructUnmarshaler(t, tagKey, opts)
+}
+
+// newTaggedStructUnmarshaler creates a struct unmarshaler that reads the names
+// and options of the fields from the struct tags with the given key.
+func newTaggedStructUnmarshaler(t reflect.Type, key string, opts *UnmarshalOptions) (*structUnmarshaler, error) {
 	if t.Kind() != reflect.Struct {
-		return nil, &wrongKindError{Expected: reflect.Struct, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Struct, Actual: t}
 	}
 
 	su := &structUnmarshaler{
-		Type: t,
+		Type:           t,
+		TagKey:         key,
+		AfterUnmarshal: implementsHook(t, afterUnmarshalQSInterfaceType),
+		Validate:       implementsHook(t, validateInterfaceType),
 	}
 
 	for i, numField := 0, t.NumField(); i < numField; i++ {
 		sf := t.Field(i)
-		vum, fum, err := newFieldUnmarshaler(sf, opts)
+		vum, fum, err := newFieldUnmarshaler(sf, key, opts)
 		if err != nil {
-			return nil, fmt.Errorf("error creating unmarshaler for field %v of struct %v :: %v",
+			return nil, fmt.Errorf("error creating unmarshaler for field %v of struct %v :: %w",
 				sf.Name, t, err)
 		}
 		if vum != nil {
+			remain := hasRemainField(vum)
+			if remain && su.Remain {
+				return nil, fmt.Errorf("struct %v has more than one field with the remain option", t)
+			}
+			su.Remain = su.Remain || remain
 			su.EmbeddedFields = append(su.EmbeddedFields, embeddedFieldUnmarshaler{
 				FieldIndex:        i,
 				ValuesUnmarshaler: vum,
+				Remain:            remain,
 			})
 		}
 		if fum != nil {
 			fum.FieldIndex = i
+			fum.BaseOpts, fum.Opts = opts, opts.fieldOptions(fum.Tag)
+			if fum.Tag.Remain {
+				if su.Remain {
+					return nil, fmt.Errorf("struct %v has more than one field with the remain option", t)
+				}
+				su.RemainField = fum
+				su.Remain = true
+				continue
+			}
 			su.Fields = append(su.Fields, fum)
 		}
 	}
 
+	own := make([]fieldKey, len(su.Fields))
+	for i, fum := range su.Fields {
+		own[i] = fieldKey{Key: fum.Tag.Name, Field: t.Field(fum.FieldIndex).Name}
+	}
+	embedded := make([][]fieldKey, len(su.EmbeddedFields))
+	for i, ef := range su.EmbeddedFields {
+		embedded[i] = embeddedFieldKeys(t.Field(ef.FieldIndex).Name, ef.ValuesUnmarshaler)
+	}
+	keys, shadowed, err := resolveFieldKeys(t, own, embedded)
+	if err != nil {
+		return nil, err
+	}
+	su.Keys = keys
+	for i := range su.EmbeddedFields {
+		su.EmbeddedFields[i].ShadowedKeys = shadowed[i]
+	}
+
 	return su, nil
 }
 
-func newFieldUnmarshaler(sf reflect.StructField, opts *UnmarshalOptions) (vum ValuesUnmarshaler, fum *fieldUnmarshaler, err error) {
-	skip, tag, err := getStructFieldInfo(sf, opts.NameTransformer, MPUnspecified, opts.DefaultUnmarshalPresence)
+func newFieldUnmarshaler(sf reflect.StructField, key string, opts *UnmarshalOptions) (vum ValuesUnmarshaler, fum *fieldUnmarshaler, err error) {
+	skip, tag, err := getStructFieldInfo(sf, key, opts.NameTransformer, MPUnspecified, opts.DefaultUnmarshalPresence)
 	if skip || err != nil {
 		return
 	}
+	if err = checkFieldStyle(sf.Type, tag); err != nil {
+		return
+	}
+	if err = checkTimeLayout(sf.Type, tag); err != nil {
+		return
+	}
+	if err = checkConstraints(sf.Type, tag); err != nil {
+		return
+	}
+	if tag.Remain {
+		if err = checkRemainField(sf, tag); err == nil {
+			fum = &fieldUnmarshaler{Tag: tag}
+		}
+		return
+	}
 
 	t := sf.Type
 	if sf.Anonymous {
-		vum, err = opts.ValuesUnmarshalerFactory.ValuesUnmarshaler(t, opts)
-		if err == nil {
-			// We can end up here for example in case of an embedded struct.
+		if isRecursiveType(t, key) {
+			err = fmt.Errorf("recursive embedded type: %v", t)
 			return
 		}
+		if key == headerTagKey {
+			vum, skip, err = newEmbeddedHeaderUnmarshaler(sf, opts)
+			if vum != nil || skip || err != nil {
+				return
+			}
+		} else {
+			vum, err = opts.ValuesUnmarshalerFactory.ValuesUnmarshaler(t, opts)
+			if err == nil {
+				// We can end up here for example in case of an embedded struct.
+				return
+			}
+		}
 	}
 
 	um, err := opts.UnmarshalerFactory.Unmarshaler(t, opts)
+	if err == nil {
+		if tag.HasDefault {
+			// Validating the default value by unmarshaling it into a
+			// temporary value.
+			err = um.Unmarshal(reflect.New(t).Elem(), []string{tag.Default}, opts.fieldOptions(tag))
+			if err != nil {
+				err = fmt.Errorf("invalid default value %q :: %w", tag.Default, err)
+				return
+			}
+		}
+		fum = &fieldUnmarshaler{
+			Unmarshaler: um,
+			Tag:         tag,
+		}
+		return
+	}
+	// Headers are flat: they can't hold nested values.
+	if key == headerTagKey || !isNestedType(t) {
+		return
+	}
+	if tag.HasDefault || tag.Constraints != nil {
+		err = fmt.Errorf("the default and constraint options can't be used with nested type %v", t)
+		return
+	}
+
+	if isRecursiveType(t, key) {
+		err = fmt.Errorf("recursive nested type: %v", t)
+		return
+	}
+	nvum, err := newNestedUnmarshaler(t, opts)
 	if err != nil {
 		return
 	}
 	fum = &fieldUnmarshaler{
-		Unmarshaler: um,
-		Tag:         tag,
+		ValuesUnmarshaler: nvum,
+		Tag:               tag,
 	}
 	return
 }
 
+// newNestedUnmarshaler creates a ValuesUnmarshaler for a nested type.
+// See isNestedType.
+func newNestedUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (ValuesUnmarshaler, error) {
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice:
+		return newIndexedUnmarshaler(t, opts)
+	default:
+		return opts.ValuesUnmarshalerFactory.ValuesUnmarshaler(t, opts)
+	}
+}
+
 func (p *structUnmarshaler) UnmarshalValues(v reflect.Value, vs url.Values, opts *UnmarshalOptions) error {
 	t := v.Type()
 	if t != p.Type {
-		return &wrongTypeError{Actual: t, Expected: p.Type}
+		return &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 
-	// TODO: use a StructError error type in the function to generate
-	// error messages prefixed with the name of the struct type.
-
+	errs := fieldErrors{Struct: t, Collect: opts.CollectErrors}
 	for _, fum := range p.Fields {
+		fopts := fum.options(opts)
+		fieldName := t.Field(fum.FieldIndex).Name
+		if fum.ValuesUnmarshaler != nil {
+			nvs := subValues(vs, fum.Tag.Name, fopts.KeySyntax)
+			if len(nvs) == 0 {
+				if fum.Tag.UnmarshalPresence == Req {
+					if errs.add(fieldName, fum.Tag.Name, nil, fopts.KeySyntax, ErrRequiredField) {
+						return errs.err()
+					}
+					continue
+				}
+				// The fields of a missing nested value keep their
+				// values: their req options, defaults and hooks
+				// don't apply.
+				if fum.Tag.UnmarshalPresence != Nil {
+					initNilValue(v.Field(fum.FieldIndex))
+				}
+				continue
+			}
+			err := fum.ValuesUnmarshaler.UnmarshalValues(v.Field(fum.FieldIndex), nvs, fopts)
+			if err != nil && errs.add(fieldName, fum.Tag.Name, nil, fopts.KeySyntax, err) {
+				return errs.err()
+			}
+			continue
+		}
+
 		a, ok := vs[fum.Tag.Name]
-		if !ok {
-			if fum.Tag.UnmarshalPresence == Req {
-				return &reqError{
-					Message:   fmt.Sprintf("missing required field %q in struct %v", fum.Tag.Name, t),
-					FieldName: fum.Tag.Name,
+		switch {
+		case p.TagKey == headerTagKey:
+			a = headerFieldValues(a, fum.Unmarshaler)
+		case isMultiValueUnmarshaler(fum.Unmarshaler):
+			var err error
+			a, ok, err = fopts.ArrayFormat.values(vs, fum.Tag.Name, fopts.sliceIndexLimit())
+			if err != nil {
+				if errs.add(fieldName, fum.Tag.Name, nil, fopts.KeySyntax, err) {
+					return errs.err()
 				}
+				continue
 			}
-			if fum.Tag.UnmarshalPresence == Nil {
+		}
+		if !ok {
+			if fum.Tag.HasDefault {
+				a = []string{fum.Tag.Default}
+			} else if fum.Tag.UnmarshalPresence == Req {
+				if errs.add(fieldName, fum.Tag.Name, nil, fopts.KeySyntax, ErrRequiredField) {
+					return errs.err()
+				}
+				continue
+			} else if fum.Tag.UnmarshalPresence == Nil || p.TagKey == headerTagKey {
+				// Absent headers leave their fields alone even in case
+				// of the Opt presence.
 				continue
 			}
 		}
-		err := fum.Unmarshaler.Unmarshal(v.Field(fum.FieldIndex), a, opts)
-		if err != nil {
-			return fmt.Errorf("error unmarshaling url.Values entry %q :: %v", fum.Tag.Name, err)
+		var err error
+		if !ok && fum.Tag.HasDefault {
+			err = unmarshalDefault(fum.Unmarshaler, v.Field(fum.FieldIndex), a, fopts)
+		} else {
+			err = fum.Unmarshaler.Unmarshal(v.Field(fum.FieldIndex), a, fopts)
+		}
+		if err == nil && fum.Tag.Constraints != nil && (ok || fum.Tag.HasDefault) {
+			err = checkConstraintValues(fum.Tag.Constraints, v.Field(fum.FieldIndex))
+		}
+		if err != nil && errs.add(fieldName, fum.Tag.Name, a, fopts.KeySyntax, err) {
+			return errs.err()
 		}
 	}
 
-	for _, ef := range p.EmbeddedFields {
-		err := ef.ValuesUnmarshaler.UnmarshalValues(v.Field(ef.FieldIndex), vs, opts)
-		if err != nil {
-			if _, ok := IsRequiredFieldError(err); ok {
-				name := t.Field(ef.FieldIndex).Name
-				return &reqError{
-					Message:   fmt.Sprintf("embedded field %q :: %v", name, err),
-					FieldName: name,
-				}
-			}
-			return fmt.Errorf("error unmarshaling embedded field %q :: %v", t.Field(ef.FieldIndex).Name, err)
+	for i, ef := range p.EmbeddedFields {
+		evs := withoutShadowedKeys(vs, ef.ShadowedKeys, opts.KeySyntax)
+		if ef.Remain {
+			evs = p.withoutClaimedKeys(evs, i, opts)
+		}
+		err := ef.ValuesUnmarshaler.UnmarshalValues(v.Field(ef.FieldIndex), evs, opts)
+		if err != nil && errs.add(t.Field(ef.FieldIndex).Name, "", nil, opts.KeySyntax, err) {
+			return errs.err()
 		}
 	}
 
-	return nil
+	if p.RemainField != nil {
+		p.unmarshalRemain(v.Field(p.RemainField.FieldIndex), vs, opts)
+	}
+
+	if err := errs.err(); err != nil {
+		return err
+	}
+	// The hooks aren't called after a failure because they would have to
+	// deal with partially unmarshaled structs.
+	return callAfterUnmarshal(v, opts, p.AfterUnmarshal, p.Validate)
+}
+
+// initNilValue initialises the nil pointers, slices and maps of a missing
+// nested field with Opt presence.
+func initNilValue(v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	}
+}
+
+// unmarshalRemain stores the keys of vs that aren't consumed by the other
+// fields of the struct in the map field with the remain tag option.
+func (p *structUnmarshaler) unmarshalRemain(v reflect.Value, vs url.Values, opts *UnmarshalOptions) {
+	if v.IsNil() && p.RemainField.Tag.UnmarshalPresence != Nil {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	for k, a := range vs {
+		if p.claimsFieldKey(k, -1, opts) {
+			continue
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(a))
+	}
 }
 
 type mapUnmarshaler struct {
 	Type            reflect.Type
 	ElemType        reflect.Type
 	ElemUnmarshaler Unmarshaler
+	// ElemValuesUnmarshaler is used instead of ElemUnmarshaler when the map
+	// values are nested values (e.g.: structs) that are unmarshaled from
+	// several keys prefixed with the map key.
+	ElemValuesUnmarshaler ValuesUnmarshaler
 }
 
 func newMapUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (ValuesUnmarshaler, error) {
 	if t.Kind() != reflect.Map {
-		return nil, &wrongKindError{Expected: reflect.Map, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Map, Actual: t}
 	}
 
-	if t.Key() != stringType {
-		return nil, fmt.Errorf("map key type is expected to be string: %v", t)
+	if err := checkMapKeyType(t, false); err != nil {
+		return nil, err
 	}
 
 	et := t.Elem()
 	um, err := opts.UnmarshalerFactory.Unmarshaler(et, opts)
-	if err != nil {
-		// TODO: use a MapError error type in the function to generate
-		// error messages prefixed with the name of the struct type.
-		return nil, fmt.Errorf("error getting unmarshaler for map value type %v :: %v", et, err)
+	if err == nil {
+		return &mapUnmarshaler{
+			Type:            t,
+			ElemType:        et,
+			ElemUnmarshaler: um,
+		}, nil
 	}
-
-	return &mapUnmarshaler{
-		Type:            t,
-		ElemType:        et,
-		ElemUnmarshaler: um,
-	}, nil
+	if isNestedType(et) && !isRecursiveType(et, tagKey) {
+		var vum ValuesUnmarshaler
+		vum, err = newNestedUnmarshaler(et, opts)
+		if err == nil {
+			return &mapUnmarshaler{
+				Type:                  t,
+				ElemType:              et,
+				ElemValuesUnmarshaler: vum,
+			}, nil
+		}
+	}
+	// TODO: use a MapError error type in the function to generate
+	// error messages prefixed with the name of the struct type.
+	return nil, fmt.Errorf("error getting unmarshaler for map value type %v :: %w", et, err)
 }
 
 func (p *mapUnmarshaler) UnmarshalValues(v reflect.Value, vs url.Values, opts *UnmarshalOptions) error {
 	t := v.Type()
 	if t != p.Type {
-		return &wrongTypeError{Actual: t, Expected: p.Type}
+		return &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 
 	if v.IsNil() {
 		v.Set(reflect.MakeMap(t))
 	}
 
+	if p.ElemValuesUnmarshaler != nil {
+		return p.unmarshalNestedValues(v, vs, opts)
+	}
+
+	errs := fieldErrors{Collect: opts.CollectErrors}
 	for k, a := range vs {
+		key, err := unmarshalMapKey(k, t.Key())
+		if err != nil {
+			if errs.add(fmt.Sprintf("[%q]", k), k, a, opts.KeySyntax, &MapKeyError{Key: k, Type: t.Key(), Err: err}) {
+				return errs.err()
+			}
+			continue
+		}
 		item := reflect.New(p.ElemType).Elem()
-		err := p.ElemUnmarshaler.Unmarshal(item, a, opts)
+		err = p.ElemUnmarshaler.Unmarshal(item, a, opts)
 		if err != nil {
-			return fmt.Errorf("error unmarshaling key %q :: %v", k, err)
+			if errs.add(fmt.Sprintf("[%q]", k), k, a, opts.KeySyntax, err) {
+				return errs.err()
+			}
+			continue
 		}
-		v.SetMapIndex(reflect.ValueOf(k), item)
+		v.SetMapIndex(key, item)
 	}
 
-	return nil
+	return errs.err()
+}
+
+// unmarshalNestedValues groups the keys of vs by their first segment and
+// unmarshals each group into the map item with the same key.
+func (p *mapUnmarshaler) unmarshalNestedValues(v reflect.Value, vs url.Values, opts *UnmarshalOptions) error {
+	items := make(map[string]url.Values)
+	for k, a := range vs {
+		head, rest, ok := opts.KeySyntax.cut(k)
+		if !ok {
+			continue
+		}
+		if items[head] == nil {
+			items[head] = make(url.Values)
+		}
+		items[head][rest] = a
+	}
+
+	errs := fieldErrors{Collect: opts.CollectErrors}
+	for k, ivs := range items {
+		key, err := unmarshalMapKey(k, v.Type().Key())
+		if err != nil {
+			if errs.add(fmt.Sprintf("[%q]", k), k, nil, opts.KeySyntax, &MapKeyError{Key: k, Type: v.Type().Key(), Err: err}) {
+				return errs.err()
+			}
+			continue
+		}
+		item := reflect.New(p.ElemType).Elem()
+		if old := v.MapIndex(key); old.IsValid() {
+			item.Set(old)
+		}
+		err = p.ElemValuesUnmarshaler.UnmarshalValues(item, ivs, opts)
+		if err != nil {
+			if errs.add(fmt.Sprintf("[%q]", k), k, nil, opts.KeySyntax, err) {
+				return errs.err()
+			}
+			continue
+		}
+		v.SetMapIndex(key, item)
+	}
+
+	return errs.err()
 }
 
 type ptrValuesUnmarshaler struct {
@@ -187,7 +503,10 @@ type ptrValuesUnmarshaler struct {
 
 func newPtrValuesUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (ValuesUnmarshaler, error) {
 	if t.Kind() != reflect.Ptr {
-		return nil, &wrongKindError{Expected: reflect.Ptr, Actual: t}
+		return nil, &WrongKindError{Expected: reflect.Ptr, Actual: t}
+	}
+	if isRecursiveElemType(t) {
+		return nil, &UnhandledTypeError{Type: t}
 	}
 	et := t.Elem()
 	eu, err := opts.ValuesUnmarshalerFactory.ValuesUnmarshaler(et, opts)
@@ -204,10 +523,130 @@ func newPtrValuesUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (ValuesUnma
 func (p *ptrValuesUnmarshaler) UnmarshalValues(v reflect.Value, vs url.Values, opts *UnmarshalOptions) error {
 	t := v.Type()
 	if t != p.Type {
-		return &wrongTypeError{Actual: t, Expected: p.Type}
+		return &WrongTypeError{Actual: t, Expected: p.Type}
 	}
 	if v.IsNil() {
 		v.Set(reflect.New(p.ElemType))
 	}
 	return p.ElemUnmarshaler.UnmarshalValues(v.Elem(), vs, opts)
 }
+
+// indexedUnmarshaler implements ValuesUnmarshaler. It unmarshals the items of
+// arrays and slices from keys that contain explicit indices. The indices can
+// be sparse and out of order. Slices are resized to hold the item with the
+// largest index (or all items with the CompactSlices option): the items of
+// pre-filled slices beyond that length are dropped.
+type indexedUnmarshaler struct {
+	Type                  reflect.Type
+	ElemUnmarshaler       Unmarshaler
+	ElemValuesUnmarshaler ValuesUnmarshaler
+}
+
+func newIndexedUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (ValuesUnmarshaler, error) {
+	k := t.Kind()
+	if k != reflect.Array && k != reflect.Slice {
+		return nil, &WrongKindError{Expected: reflect.Slice, Actual: t}
+	}
+
+	et := t.Elem()
+	eu, err := opts.UnmarshalerFactory.Unmarshaler(et, opts)
+	if err == nil {
+		return &indexedUnmarshaler{
+			Type:            t,
+			ElemUnmarshaler: eu,
+		}, nil
+	}
+	if !isNestedType(et) {
+		return nil, err
+	}
+
+	evu, err := newNestedUnmarshaler(et, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &indexedUnmarshaler{
+		Type:                  t,
+		ElemValuesUnmarshaler: evu,
+	}, nil
+}
+
+func (p *indexedUnmarshaler) UnmarshalValues(v reflect.Value, vs url.Values, opts *UnmarshalOptions) error {
+	t := v.Type()
+	if t != p.Type {
+		return &WrongTypeError{Actual: t, Expected: p.Type}
+	}
+
+	maxIndex := opts.sliceIndexLimit()
+	if t.Kind() == reflect.Array && !opts.CompactSlices {
+		maxIndex = t.Len() - 1
+	}
+
+	errs := fieldErrors{Collect: opts.CollectErrors}
+	items := make(map[int]url.Values)
+	for k, a := range vs {
+		head, rest, nested := opts.KeySyntax.cut(k)
+		if nested != (p.ElemValuesUnmarshaler != nil) {
+			continue
+		}
+		i, err := parseIndex(head, maxIndex)
+		if err != nil {
+			var values []string
+			if !nested {
+				values = a
+			}
+			index := "[" + head + "]"
+			if errs.add(index, index, values, opts.KeySyntax, err) {
+				return errs.err()
+			}
+			continue
+		}
+		if items[i] == nil {
+			items[i] = make(url.Values)
+		}
+		items[i][rest] = a
+	}
+
+	indices := make([]int, 0, len(items))
+	for i := range items {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	// n is the number of items needed to hold the largest index or the
+	// number of indices if the items are compacted.
+	n := len(indices)
+	if n != 0 && !opts.CompactSlices {
+		n = indices[n-1] + 1
+	}
+	if t.Kind() == reflect.Array {
+		if n > t.Len() {
+			return fmt.Errorf("%v items don't fit into an array of length %v", n, t.Len())
+		}
+	} else if v.IsNil() || v.Len() < n {
+		s := reflect.MakeSlice(t, n, n)
+		reflect.Copy(s, v)
+		v.Set(s)
+	} else {
+		v.SetLen(n)
+	}
+
+	for pos, i := range indices {
+		elem := v.Index(i)
+		if opts.CompactSlices {
+			elem = v.Index(pos)
+		}
+		var err error
+		if p.ElemValuesUnmarshaler != nil {
+			err = p.ElemValuesUnmarshaler.UnmarshalValues(elem, items[i], opts)
+		} else {
+			err = p.ElemUnmarshaler.Unmarshal(elem, items[i][""], opts)
+		}
+		if err != nil {
+			index := "[" + strconv.Itoa(i) + "]"
+			if errs.add(index, index, items[i][""], opts.KeySyntax, err) {
+				return errs.err()
+			}
+		}
+	}
+	return errs.err()
+}
@@ -1,6 +1,7 @@
 package qs
 
 import (
+	"encoding"
 	"net/url"
 	"reflect"
 )
@@ -64,8 +65,9 @@ func newValuesUnmarshalerFactory() ValuesUnmarshalerFactory {
 func newUnmarshalerFactory() UnmarshalerFactory {
 	return &unmarshalerFactory{
 		Types: map[reflect.Type]Unmarshaler{
-			timeType: primitiveUnmarshalerFunc(unmarshalTime),
-			urlType:  primitiveUnmarshalerFunc(unmarshalURL),
+			timeType:     primitiveUnmarshalerFunc(unmarshalTime),
+			durationType: primitiveUnmarshalerFunc(unmarshalDuration),
+			urlType:      primitiveUnmarshalerFunc(unmarshalURL),
 		},
 		KindSubRegistries: map[reflect.Kind]UnmarshalerFactory{
 			reflect.Ptr:   unmarshalerFactoryFunc(newPtrUnmarshaler),
@@ -99,11 +101,14 @@ type valuesUnmarshalerFactory struct {
 }
 
 func (p *valuesUnmarshalerFactory) ValuesUnmarshaler(t reflect.Type, opts *UnmarshalOptions) (ValuesUnmarshaler, error) {
+	if hasGeneratedMethod(t, unmarshalQSValuesInterfaceType) {
+		return newGeneratedUnmarshaler(t, opts)
+	}
 	if subFactory, ok := p.KindSubRegistries[t.Kind()]; ok {
 		return subFactory.ValuesUnmarshaler(t, opts)
 	}
 
-	return nil, &unhandledTypeError{Type: t}
+	return nil, &UnhandledTypeError{Type: t}
 }
 
 // unmarshalerFactory implements the UnmarshalerFactory interface.
@@ -114,15 +119,22 @@ type unmarshalerFactory struct {
 }
 
 var unmarshalQSInterfaceType = reflect.TypeOf((*UnmarshalQS)(nil)).Elem()
+var textUnmarshalerInterfaceType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 
 func (p *unmarshalerFactory) Unmarshaler(t reflect.Type, opts *UnmarshalOptions) (Unmarshaler, error) {
 	if unmarshaler, ok := p.Types[t]; ok {
 		return unmarshaler, nil
 	}
 
+	if isOptionalType(t) {
+		return newOptionalUnmarshaler(t, opts)
+	}
 	if reflect.PtrTo(t).Implements(unmarshalQSInterfaceType) {
 		return unmarshalerFunc(unmarshalWithUnmarshalQS), nil
 	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerInterfaceType) {
+		return primitiveUnmarshalerFunc(unmarshalWithUnmarshalText), nil
+	}
 
 	k := t.Kind()
 	if subFactory, ok := p.KindSubRegistries[k]; ok {
@@ -132,7 +144,7 @@ func (p *unmarshalerFactory) Unmarshaler(t reflect.Type, opts *UnmarshalOptions)
 		return unmarshaler, nil
 	}
 
-	return nil, &unhandledTypeError{Type: t}
+	return nil, &UnhandledTypeError{Type: t}
 }
 
 // valuesUnmarshalerFactoryFunc implements the UnmarshalerFactory interface.